@@ -0,0 +1,241 @@
+package perf
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+// Lookup tables map every byte to its ASCII upper- or lowercase form and
+// leave all other bytes, including UTF-8 sequences, unchanged. A table load
+// costs the same for every byte, where a range check would branch on the
+// data.
+var upperASCIITable, lowerASCIITable = func() (up, low [256]byte) {
+	for i := range 256 {
+		up[i], low[i] = byte(i), byte(i)
+	}
+	for c := 'a'; c <= 'z'; c++ {
+		up[c] = byte(c - 'a' + 'A')
+		low[c-'a'+'A'] = byte(c)
+	}
+	return up, low
+}()
+
+// ToUpperASCIIInPlace uppercases the ASCII letters in b, overwriting it.
+// Any slice sharing b's backing array sees the change.
+func ToUpperASCIIInPlace(b []byte) {
+	for i, c := range b {
+		b[i] = upperASCIITable[c]
+	}
+}
+
+// ToLowerASCIIInPlace lowercases the ASCII letters in b, overwriting it.
+func ToLowerASCIIInPlace(b []byte) {
+	for i, c := range b {
+		b[i] = lowerASCIITable[c]
+	}
+}
+
+// AppendUpperASCII appends src with its ASCII letters uppercased to dst.
+// src is not modified.
+func AppendUpperASCII(dst, src []byte) []byte {
+	n := len(dst)
+	dst = append(dst, src...)
+	ToUpperASCIIInPlace(dst[n:])
+	return dst
+}
+
+// AppendLowerASCII appends src with its ASCII letters lowercased to dst.
+func AppendLowerASCII(dst, src []byte) []byte {
+	n := len(dst)
+	dst = append(dst, src...)
+	ToLowerASCIIInPlace(dst[n:])
+	return dst
+}
+
+// appendUpperASCIIBranchy is the range-check loop bytes.ToUpper uses for
+// ASCII input, writing into dst. It separates the cost of branching on the
+// data from the cost of allocating.
+func appendUpperASCIIBranchy(dst, src []byte) []byte {
+	n := len(dst)
+	dst = append(dst, src...)
+	out := dst[n:]
+	for i, c := range out {
+		if 'a' <= c && c <= 'z' {
+			out[i] = c - ('a' - 'A')
+		}
+	}
+	return dst
+}
+
+func TestASCIICaseMixed(t *testing.T) {
+	src := []byte("Hello, World! GET /api/v1/Users?id=42&Sort=ASC\t{ok}")
+	wantUp := "HELLO, WORLD! GET /API/V1/USERS?ID=42&SORT=ASC\t{OK}"
+	wantLow := "hello, world! get /api/v1/users?id=42&sort=asc\t{ok}"
+
+	if got := AppendUpperASCII(nil, src); string(got) != wantUp {
+		t.Errorf("AppendUpperASCII = %q, want %q", got, wantUp)
+	}
+	if got := AppendLowerASCII(nil, src); string(got) != wantLow {
+		t.Errorf("AppendLowerASCII = %q, want %q", got, wantLow)
+	}
+	if string(src) != "Hello, World! GET /api/v1/Users?id=42&Sort=ASC\t{ok}" {
+		t.Fatalf("Append variants modified src: %q", src)
+	}
+	b := bytes.Clone(src)
+	ToUpperASCIIInPlace(b)
+	if string(b) != wantUp {
+		t.Errorf("ToUpperASCIIInPlace = %q, want %q", b, wantUp)
+	}
+	ToLowerASCIIInPlace(b)
+	if string(b) != wantLow {
+		t.Errorf("ToLowerASCIIInPlace = %q, want %q", b, wantLow)
+	}
+}
+
+func TestASCIICaseMatchesBytesOnASCII(t *testing.T) {
+	for _, src := range asciiBenchInputs[:20] {
+		if got, want := AppendUpperASCII(nil, src), bytes.ToUpper(src); !bytes.Equal(got, want) {
+			t.Fatalf("AppendUpperASCII differs from bytes.ToUpper")
+		}
+		if got, want := AppendLowerASCII(nil, src), bytes.ToLower(src); !bytes.Equal(got, want) {
+			t.Fatalf("AppendLowerASCII differs from bytes.ToLower")
+		}
+		if got, want := appendUpperASCIIBranchy(nil, src), bytes.ToUpper(src); !bytes.Equal(got, want) {
+			t.Fatalf("appendUpperASCIIBranchy differs from bytes.ToUpper")
+		}
+	}
+}
+
+func TestASCIICaseLeavesNonLettersAlone(t *testing.T) {
+	for i := range 256 {
+		c := byte(i)
+		isUpper, isLower := c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z'
+		up, low := AppendUpperASCII(nil, []byte{c})[0], AppendLowerASCII(nil, []byte{c})[0]
+		switch {
+		case isLower:
+			if up != c-32 || low != c {
+				t.Errorf("%q: upper %q, lower %q", c, up, low)
+			}
+		case isUpper:
+			if up != c || low != c+32 {
+				t.Errorf("%q: upper %q, lower %q", c, up, low)
+			}
+		default:
+			if up != c || low != c {
+				t.Errorf("byte %#x changed: upper %#x, lower %#x", c, up, low)
+			}
+		}
+	}
+	// UTF-8 stays valid and non-ASCII letters are left as they are.
+	if got := AppendUpperASCII(nil, []byte("café ß")); string(got) != "CAFé ß" {
+		t.Errorf("AppendUpperASCII(café ß) = %q", got)
+	}
+}
+
+func TestASCIICaseInPlaceMutatesSharedBacking(t *testing.T) {
+	line := []byte("user=alice action=login")
+	user := line[5:10] // shares line's backing array
+	ToUpperASCIIInPlace(user)
+	if string(line) != "user=ALICE action=login" {
+		t.Fatalf("line = %q; in-place conversion of a subslice should show through", line)
+	}
+	// The append variant writes to its own buffer and leaves line alone.
+	out := AppendLowerASCII(nil, user)
+	if string(out) != "alice" || string(line) != "user=ALICE action=login" {
+		t.Fatalf("out = %q, line = %q", out, line)
+	}
+}
+
+func TestASCIICaseAppendKeepsPrefix(t *testing.T) {
+	if got := AppendUpperASCII([]byte("hdr:"), []byte("body")); string(got) != "hdr:BODY" {
+		t.Fatalf("AppendUpperASCII = %q", got)
+	}
+}
+
+func TestASCIICaseReusedBufferDoesNotAllocate(t *testing.T) {
+	var buf []byte
+	buf = AppendUpperASCII(buf[:0], asciiBenchInputs[0])
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, src := range asciiBenchInputs[:10] {
+			buf = AppendUpperASCII(buf[:0], src)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("conversions into a reused buffer allocated %v times", allocs)
+	}
+}
+
+// asciiBenchInputs look like HTTP request lines and headers: mixed-case
+// ASCII with digits, punctuation, and spaces.
+var (
+	asciiBenchInputs = makeASCIIInputs(1000, 4096, 31)
+	// The in-place benchmark overwrites its inputs, so it gets its own copy.
+	asciiInPlaceInputs = makeASCIIInputs(1000, 4096, 31)
+	asciiLenSink       int
+)
+
+func makeASCIIInputs(count, size int, seed uint64) [][]byte {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 /:=&?-_.,;\t"
+	rng := rand.New(rand.NewPCG(seed, seed))
+	out := make([][]byte, count)
+	for i := range out {
+		b := make([]byte, size)
+		for j := range b {
+			b[j] = alphabet[rng.IntN(len(alphabet))]
+		}
+		out[i] = b
+	}
+	return out
+}
+
+// Each operation uppercases all 1,000 inputs of 4 KB.
+
+func BenchmarkASCIIUpperBytesToUpper(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, src := range asciiBenchInputs {
+			n += len(bytes.ToUpper(src))
+		}
+		asciiLenSink = n
+	}
+}
+
+func BenchmarkASCIIUpperInPlace(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, src := range asciiInPlaceInputs {
+			ToUpperASCIIInPlace(src)
+			n += len(src)
+		}
+		asciiLenSink = n
+	}
+}
+
+func BenchmarkASCIIUpperReusedBuffer(b *testing.B) {
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, src := range asciiBenchInputs {
+			buf = AppendUpperASCII(buf[:0], src)
+			n += len(buf)
+		}
+		asciiLenSink = n
+	}
+}
+
+func BenchmarkASCIIUpperReusedBranchy(b *testing.B) {
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, src := range asciiBenchInputs {
+			buf = appendUpperASCIIBranchy(buf[:0], src)
+			n += len(buf)
+		}
+		asciiLenSink = n
+	}
+}
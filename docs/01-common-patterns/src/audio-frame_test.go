@@ -0,0 +1,242 @@
+package perf
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const (
+	audioSampleRate = 48_000
+	audioFrameSize  = 480 // 10 ms per callback
+)
+
+// audioFrame is one callback's worth of mono samples.
+type audioFrame struct {
+	samples []float32
+}
+
+// audioFramePool recycles frames and counts how many it had to create.
+type audioFramePool struct {
+	pool    sync.Pool
+	created atomic.Int64
+}
+
+func newAudioFramePool() *audioFramePool {
+	p := &audioFramePool{}
+	p.pool.New = func() any {
+		p.created.Add(1)
+		return &audioFrame{samples: make([]float32, audioFrameSize)}
+	}
+	return p
+}
+
+func (p *audioFramePool) Get() *audioFrame { return p.pool.Get().(*audioFrame) }
+
+func (p *audioFramePool) Put(f *audioFrame) { p.pool.Put(f) }
+
+// toneSource produces a sine wave from a precomputed wavetable, the way a
+// synthesizer or decoder fills frames without per-sample trig calls.
+type toneSource struct {
+	table []float32
+	phase float64
+	step  float64
+}
+
+func newToneSource(freq float64) *toneSource {
+	table := make([]float32, 1024)
+	for i := range table {
+		table[i] = float32(math.Sin(2 * math.Pi * float64(i) / float64(len(table))))
+	}
+	return &toneSource{table: table, step: freq * float64(len(table)) / audioSampleRate}
+}
+
+func (s *toneSource) Fill(dst []float32) {
+	n := float64(len(s.table))
+	for i := range dst {
+		dst[i] = s.table[int(s.phase)]
+		s.phase += s.step
+		if s.phase >= n {
+			s.phase -= n
+		}
+	}
+}
+
+// audioProcessor applies gain and a one-pole low-pass filter in place. The
+// filter state carries across frames, so frame boundaries must be seamless.
+type audioProcessor struct {
+	gain, alpha float32
+	state       float32
+}
+
+func (p *audioProcessor) Process(samples []float32) {
+	for i, x := range samples {
+		p.state += p.alpha * (x*p.gain - p.state)
+		samples[i] = p.state
+	}
+}
+
+// audioSink consumes processed frames. Implementations must not retain the
+// slice after Write returns.
+type audioSink interface {
+	Write(samples []float32)
+}
+
+// peakSink tracks the output peak, standing in for a device write.
+type peakSink struct {
+	peak float32
+}
+
+func (s *peakSink) Write(samples []float32) {
+	for _, v := range samples {
+		if v > s.peak {
+			s.peak = v
+		} else if -v > s.peak {
+			s.peak = -v
+		}
+	}
+}
+
+// renderAudioFresh allocates a new frame for every callback.
+func renderAudioFresh(src *toneSource, proc *audioProcessor, sink audioSink, frames int) {
+	for i := 0; i < frames; i++ {
+		f := &audioFrame{samples: make([]float32, audioFrameSize)}
+		src.Fill(f.samples)
+		proc.Process(f.samples)
+		sink.Write(f.samples)
+	}
+}
+
+// renderAudioPooled draws each frame from pool and returns it after the
+// sink has consumed it.
+func renderAudioPooled(pool *audioFramePool, src *toneSource, proc *audioProcessor, sink audioSink, frames int) {
+	for i := 0; i < frames; i++ {
+		f := pool.Get()
+		src.Fill(f.samples)
+		proc.Process(f.samples)
+		sink.Write(f.samples)
+		pool.Put(f)
+	}
+}
+
+// recordingSink keeps a copy of everything written, for tests.
+type recordingSink struct {
+	out []float32
+}
+
+func (s *recordingSink) Write(samples []float32) { s.out = append(s.out, samples...) }
+
+func newTestProcessor() *audioProcessor { return &audioProcessor{gain: 0.8, alpha: 0.2} }
+
+func TestAudioFramesMatchContinuousSignal(t *testing.T) {
+	const frames = 50
+
+	// Reference: generate and filter the whole signal as one buffer.
+	want := make([]float32, frames*audioFrameSize)
+	newToneSource(440).Fill(want)
+	newTestProcessor().Process(want)
+
+	fresh := &recordingSink{}
+	renderAudioFresh(newToneSource(440), newTestProcessor(), fresh, frames)
+
+	pooled := &recordingSink{}
+	renderAudioPooled(newAudioFramePool(), newToneSource(440), newTestProcessor(), pooled, frames)
+
+	for name, got := range map[string][]float32{"fresh": fresh.out, "pooled": pooled.out} {
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %d samples, want %d", name, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: sample %d = %v, want %v", name, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestAudioFramesRecycled(t *testing.T) {
+	const frames = 1000
+	pool := newAudioFramePool()
+	renderAudioPooled(pool, newToneSource(440), newTestProcessor(), &peakSink{}, frames)
+	// The render loop returns each frame before taking the next, so a few
+	// frames cover every callback. Under -race, sync.Pool drops a quarter
+	// of Puts at random, and each drop costs a new frame.
+	limit := int64(4)
+	if audioRaceEnabled() {
+		limit = frames / 2
+	}
+	if created := pool.created.Load(); created > limit {
+		t.Fatalf("pool created %d frames for %d callbacks, want at most %d", created, frames, limit)
+	}
+}
+
+// audioRaceEnabled reports whether the test binary was built with -race.
+func audioRaceEnabled() bool {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return false
+	}
+	for _, s := range info.Settings {
+		if s.Key == "-race" {
+			return s.Value == "true"
+		}
+	}
+	return false
+}
+
+func TestAudioPooledDoesNotAllocate(t *testing.T) {
+	pool := newAudioFramePool()
+	src, proc, sink := newToneSource(440), newTestProcessor(), &peakSink{}
+	renderAudioPooled(pool, src, proc, sink, 1)
+	allocs := testing.AllocsPerRun(100, func() {
+		renderAudioPooled(pool, src, proc, sink, 1)
+	})
+	if allocs != 0 {
+		t.Fatalf("pooled callback allocated %v times", allocs)
+	}
+}
+
+// audioFramesPerOp is one second of audio.
+const audioFramesPerOp = audioSampleRate / audioFrameSize
+
+var audioPeak float32
+
+// reportGC reports the number of GC cycles and total stop-the-world pause
+// time per operation, measured around the benchmark loop.
+func reportGC(b *testing.B, run func()) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	run()
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gc/op")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+}
+
+func BenchmarkAudioFramesFresh(b *testing.B) {
+	src, proc, sink := newToneSource(440), newTestProcessor(), &peakSink{}
+	b.ReportAllocs()
+	reportGC(b, func() {
+		for i := 0; i < b.N; i++ {
+			renderAudioFresh(src, proc, sink, audioFramesPerOp)
+		}
+	})
+	audioPeak = sink.peak
+}
+
+func BenchmarkAudioFramesPooled(b *testing.B) {
+	pool := newAudioFramePool()
+	src, proc, sink := newToneSource(440), newTestProcessor(), &peakSink{}
+	b.ReportAllocs()
+	reportGC(b, func() {
+		for i := 0; i < b.N; i++ {
+			renderAudioPooled(pool, src, proc, sink, audioFramesPerOp)
+		}
+	})
+	audioPeak = sink.peak
+}
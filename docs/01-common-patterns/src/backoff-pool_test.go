@@ -0,0 +1,224 @@
+package perf
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// BackoffConfig describes an exponential backoff schedule.
+type BackoffConfig struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64 // fraction of each delay that may be randomly removed, 0..1
+}
+
+var defaultBackoffConfig = BackoffConfig{
+	Base:       10 * time.Millisecond,
+	Max:        2 * time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Backoff produces the delays between retries of one operation. It owns a
+// random source for jitter, which is the expensive part to set up.
+type Backoff struct {
+	cfg     BackoffConfig
+	attempt int
+	current time.Duration
+	rng     *rand.Rand
+}
+
+var backoffSeed atomic.Uint64
+
+func NewBackoff(cfg BackoffConfig) *Backoff {
+	seed := backoffSeed.Add(1)
+	b := &Backoff{cfg: cfg, rng: rand.New(rand.NewPCG(seed, seed*0x9e3779b97f4a7c15))}
+	b.Reset()
+	return b
+}
+
+// Next returns the delay before the next retry and advances the schedule.
+// The delay is the nominal exponential value reduced by up to Jitter of
+// itself, so it always lies in [nominal*(1-Jitter), nominal].
+func (b *Backoff) Next() time.Duration {
+	nominal := b.current
+	b.attempt++
+	b.current = min(time.Duration(float64(b.current)*b.cfg.Multiplier), b.cfg.Max)
+	if b.cfg.Jitter == 0 {
+		return nominal
+	}
+	return nominal - time.Duration(b.cfg.Jitter*b.rng.Float64()*float64(nominal))
+}
+
+// Attempt returns how many delays have been handed out since the last Reset.
+func (b *Backoff) Attempt() int { return b.attempt }
+
+// Reset returns the schedule to its first delay, which is Base capped at
+// Max. The random source is kept.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+	b.current = min(b.cfg.Base, b.cfg.Max)
+}
+
+var backoffPool = sync.Pool{
+	New: func() any { return NewBackoff(defaultBackoffConfig) },
+}
+
+func acquireBackoff() *Backoff {
+	b := backoffPool.Get().(*Backoff)
+	b.Reset()
+	return b
+}
+
+func releaseBackoff(b *Backoff) { backoffPool.Put(b) }
+
+var errRetriesExhausted = errors.New("retry: attempts exhausted")
+
+const maxRetryAttempts = 6
+
+// retryCall runs op until it succeeds or maxRetryAttempts is reached. Instead
+// of sleeping, it returns the total delay it would have waited.
+func retryCall(op func(attempt int) error, b *Backoff) (time.Duration, error) {
+	var waited time.Duration
+	for attempt := 0; attempt < maxRetryAttempts; attempt++ {
+		if err := op(attempt); err == nil {
+			return waited, nil
+		}
+		waited += b.Next()
+	}
+	return waited, errRetriesExhausted
+}
+
+func retryPerCall(op func(attempt int) error) (time.Duration, error) {
+	return retryCall(op, NewBackoff(defaultBackoffConfig))
+}
+
+func retryPooled(op func(attempt int) error) (time.Duration, error) {
+	b := acquireBackoff()
+	defer releaseBackoff(b)
+	return retryCall(op, b)
+}
+
+var errTransient = errors.New("transient failure")
+
+// flakyOp fails its first failures attempts.
+func flakyOp(failures int) func(attempt int) error {
+	return func(attempt int) error {
+		if attempt < failures {
+			return errTransient
+		}
+		return nil
+	}
+}
+
+func TestBackoffExponentialSchedule(t *testing.T) {
+	cfg := BackoffConfig{Base: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	b := NewBackoff(cfg)
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := b.Next(); got != w*time.Millisecond {
+			t.Fatalf("delay %d = %v, want %v", i, got, w*time.Millisecond)
+		}
+	}
+	if b.Attempt() != len(want) {
+		t.Fatalf("Attempt() = %d, want %d", b.Attempt(), len(want))
+	}
+}
+
+func TestBackoffBaseAboveMax(t *testing.T) {
+	cfg := BackoffConfig{Base: 5 * time.Second, Max: time.Second, Multiplier: 2}
+	b := NewBackoff(cfg)
+	for i := 0; i < 3; i++ {
+		if got := b.Next(); got != time.Second {
+			t.Fatalf("delay %d = %v, want Max %v", i, got, cfg.Max)
+		}
+	}
+	b.Reset()
+	if got := b.Next(); got != time.Second {
+		t.Fatalf("first delay after Reset = %v, want Max %v", got, cfg.Max)
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	cfg := BackoffConfig{Base: 100 * time.Millisecond, Max: 5 * time.Second, Multiplier: 2, Jitter: 0.5}
+	for trial := 0; trial < 200; trial++ {
+		b := NewBackoff(cfg)
+		nominal := cfg.Base
+		for i := 0; i < 10; i++ {
+			got := b.Next()
+			lo := nominal - time.Duration(cfg.Jitter*float64(nominal))
+			if got < lo || got > nominal {
+				t.Fatalf("delay %d = %v, want within [%v, %v]", i, got, lo, nominal)
+			}
+			nominal = min(nominal*2, cfg.Max)
+		}
+	}
+}
+
+func TestBackoffResetRestoresInitialState(t *testing.T) {
+	cfg := BackoffConfig{Base: 50 * time.Millisecond, Max: time.Second, Multiplier: 3}
+	b := NewBackoff(cfg)
+	first := []time.Duration{b.Next(), b.Next(), b.Next()}
+	b.Next()
+	b.Reset()
+	if b.Attempt() != 0 {
+		t.Fatalf("Attempt() after Reset = %d, want 0", b.Attempt())
+	}
+	for i, w := range first {
+		if got := b.Next(); got != w {
+			t.Fatalf("delay %d after Reset = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestRetryPooledMatchesPerCall(t *testing.T) {
+	for failures := 0; failures <= maxRetryAttempts; failures++ {
+		_, errFresh := retryPerCall(flakyOp(failures))
+		waited, errPooled := retryPooled(flakyOp(failures))
+		if (errFresh == nil) != (errPooled == nil) {
+			t.Fatalf("failures=%d: per-call err %v, pooled err %v", failures, errFresh, errPooled)
+		}
+		if failures == 0 && waited != 0 {
+			t.Fatalf("first-try success waited %v", waited)
+		}
+	}
+	if _, err := retryPooled(flakyOp(maxRetryAttempts)); !errors.Is(err, errRetriesExhausted) {
+		t.Fatalf("got %v, want errRetriesExhausted", err)
+	}
+}
+
+func TestRetryPooledAllocations(t *testing.T) {
+	op := flakyOp(3)
+	retryPooled(op)
+	allocs := testing.AllocsPerRun(1000, func() {
+		retryPooled(op)
+	})
+	if allocs != 0 {
+		t.Fatalf("pooled retry allocated %v times", allocs)
+	}
+}
+
+// retryOps is a retry-heavy mix: most calls fail at least once.
+var (
+	retryOps    = []func(int) error{flakyOp(0), flakyOp(1), flakyOp(2), flakyOp(3)}
+	retryWaited time.Duration
+)
+
+func BenchmarkRetryBackoffPerCall(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		retryWaited, _ = retryPerCall(retryOps[i%len(retryOps)])
+	}
+}
+
+func BenchmarkRetryBackoffPooled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		retryWaited, _ = retryPooled(retryOps[i%len(retryOps)])
+	}
+}
@@ -0,0 +1,182 @@
+package perf
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+const bfsNodes = 1 << 17
+
+// bfsGraph is a directed graph stored as adjacency lists.
+type bfsGraph [][]int
+
+var bfsLarge = sync.OnceValue(func() bfsGraph {
+	g := make(bfsGraph, bfsNodes)
+	for i := range g {
+		g[i] = []int{(i + 1) % bfsNodes, (i*7 + 3) % bfsNodes, (i*13 + 5) % bfsNodes, (i / 2)}
+	}
+	return g
+})
+
+// bfsAppendQueue uses the common queue = queue[1:] idiom. Popping from the
+// front never frees the consumed prefix, so append keeps reallocating.
+func bfsAppendQueue(g bfsGraph, start int, visited []bool, order []int) []int {
+	queue := []int{start}
+	visited[start] = true
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		order = append(order, v)
+		for _, w := range g[v] {
+			if !visited[w] {
+				visited[w] = true
+				queue = append(queue, w)
+			}
+		}
+	}
+	return order
+}
+
+// nodeRing is a fixed-capacity FIFO queue over a preallocated buffer.
+type nodeRing struct {
+	buf  []int
+	head int
+	size int
+}
+
+func newNodeRing(capacity int) *nodeRing {
+	return &nodeRing{buf: make([]int, capacity)}
+}
+
+func (r *nodeRing) Push(v int) {
+	r.buf[(r.head+r.size)%len(r.buf)] = v
+	r.size++
+}
+
+func (r *nodeRing) Pop() int {
+	v := r.buf[r.head]
+	r.head = (r.head + 1) % len(r.buf)
+	r.size--
+	return v
+}
+
+func (r *nodeRing) Len() int { return r.size }
+
+func (r *nodeRing) Reset() { r.head, r.size = 0, 0 }
+
+// bfsRingQueue sizes the ring to the node count; every node is enqueued at
+// most once, so the ring can never overflow.
+func bfsRingQueue(g bfsGraph, start int, visited []bool, order []int) []int {
+	q := newNodeRing(len(g))
+	q.Push(start)
+	visited[start] = true
+	for q.Len() > 0 {
+		v := q.Pop()
+		order = append(order, v)
+		for _, w := range g[v] {
+			if !visited[w] {
+				visited[w] = true
+				q.Push(w)
+			}
+		}
+	}
+	return order
+}
+
+// bfsLevelSwap processes the graph one level at a time, swapping two slices
+// so their backing arrays are reused once they reach the widest frontier.
+func bfsLevelSwap(g bfsGraph, start int, visited []bool, order []int) []int {
+	cur := []int{start}
+	var next []int
+	visited[start] = true
+	for len(cur) > 0 {
+		for _, v := range cur {
+			order = append(order, v)
+			for _, w := range g[v] {
+				if !visited[w] {
+					visited[w] = true
+					next = append(next, w)
+				}
+			}
+		}
+		cur, next = next, cur[:0]
+	}
+	return order
+}
+
+type bfsFunc func(g bfsGraph, start int, visited []bool, order []int) []int
+
+var bfsImpls = []struct {
+	name string
+	fn   bfsFunc
+}{
+	{"AppendQueue", bfsAppendQueue},
+	{"RingQueue", bfsRingQueue},
+	{"LevelSwap", bfsLevelSwap},
+}
+
+func TestBFSVisitationOrder(t *testing.T) {
+	//   0 → 1 → 3
+	//   ↓   ↓
+	//   2 → 4 → 5     6 (unreachable)
+	g := bfsGraph{
+		0: {1, 2},
+		1: {3, 4},
+		2: {4},
+		3: {},
+		4: {5, 0},
+		5: {},
+		6: {0},
+	}
+	want := []int{0, 1, 2, 3, 4, 5}
+	for _, impl := range bfsImpls {
+		got := impl.fn(g, 0, make([]bool, len(g)), nil)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: order %v, want %v", impl.name, got, want)
+		}
+	}
+}
+
+func TestBFSVisitsReachableOnce(t *testing.T) {
+	g := bfsLarge()
+	for _, impl := range bfsImpls {
+		order := impl.fn(g, 0, make([]bool, len(g)), nil)
+		if len(order) != len(g) {
+			t.Errorf("%s: visited %d nodes, want %d", impl.name, len(order), len(g))
+		}
+		seen := make([]bool, len(g))
+		for _, v := range order {
+			if seen[v] {
+				t.Fatalf("%s: node %d visited twice", impl.name, v)
+			}
+			seen[v] = true
+		}
+	}
+
+	ref := bfsAppendQueue(g, 0, make([]bool, len(g)), nil)
+	for _, impl := range bfsImpls[1:] {
+		if got := impl.fn(g, 0, make([]bool, len(g)), nil); !slices.Equal(got, ref) {
+			t.Errorf("%s: order differs from AppendQueue", impl.name)
+		}
+	}
+}
+
+var bfsSink []int
+
+func benchmarkBFS(b *testing.B, fn bfsFunc) {
+	g := bfsLarge()
+	visited := make([]bool, len(g))
+	order := make([]int, 0, len(g))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		clear(visited)
+		bfsSink = fn(g, 0, visited, order[:0])
+	}
+}
+
+func BenchmarkBFSAppendQueue(b *testing.B) { benchmarkBFS(b, bfsAppendQueue) }
+
+func BenchmarkBFSRingQueue(b *testing.B) { benchmarkBFS(b, bfsRingQueue) }
+
+func BenchmarkBFSLevelSwap(b *testing.B) { benchmarkBFS(b, bfsLevelSwap) }
@@ -0,0 +1,358 @@
+package perf
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+// BitPacker appends integers of arbitrary width, 1 to 64 bits, to a byte
+// buffer, least significant bit first. Reset keeps the buffer, so a packer
+// that encodes batch after batch stops allocating once it has seen the
+// largest one.
+type BitPacker struct {
+	buf []byte
+	acc uint64 // pending bits not yet written to buf
+	n   uint   // number of pending bits, always < 8 between calls
+}
+
+func NewBitPacker(capacity int) *BitPacker {
+	return &BitPacker{buf: make([]byte, 0, capacity)}
+}
+
+// Append adds the low bits of value. It panics if bits is out of range or
+// value doesn't fit, since either is a bug in the caller's schema.
+func (p *BitPacker) Append(value uint64, bits int) {
+	if bits < 1 || bits > 64 {
+		panic("BitPacker.Append: width out of range")
+	}
+	if bits < 64 && value>>bits != 0 {
+		panic("BitPacker.Append: value wider than width")
+	}
+	if bits > 56 {
+		// Appending more than 56 bits to up to 7 pending ones would
+		// overflow the accumulator, so wide values go in two halves.
+		p.Append(value&(1<<32-1), 32)
+		p.Append(value>>32, bits-32)
+		return
+	}
+	p.acc |= value << p.n
+	p.n += uint(bits)
+	for p.n >= 8 {
+		p.buf = append(p.buf, byte(p.acc))
+		p.acc >>= 8
+		p.n -= 8
+	}
+}
+
+// Bytes returns the packed data, with the last partial byte padded with
+// zero bits. The slice is valid until the next Append or Reset.
+func (p *BitPacker) Bytes() []byte {
+	if p.n == 0 {
+		return p.buf
+	}
+	return append(p.buf, byte(p.acc))
+}
+
+// Reset empties the packer, keeping its buffer.
+func (p *BitPacker) Reset() {
+	p.buf = p.buf[:0]
+	p.acc, p.n = 0, 0
+}
+
+var errBitUnderflow = errors.New("bitpack: not enough data")
+
+// BitUnpacker reads values written by BitPacker. The data doesn't record
+// how many values it holds; padding bits at the end read as zeros, so the
+// caller must know the count, as it knows the widths.
+type BitUnpacker struct {
+	data []byte
+	pos  int
+	acc  uint64
+	n    uint
+}
+
+func NewBitUnpacker(data []byte) *BitUnpacker {
+	return &BitUnpacker{data: data}
+}
+
+// Reset starts reading data from the beginning.
+func (u *BitUnpacker) Reset(data []byte) {
+	*u = BitUnpacker{data: data}
+}
+
+// Next returns the next value of the given width.
+func (u *BitUnpacker) Next(bits int) (uint64, error) {
+	if bits < 1 || bits > 64 {
+		panic("BitUnpacker.Next: width out of range")
+	}
+	if bits > 56 {
+		lo, err := u.Next(32)
+		if err != nil {
+			return 0, err
+		}
+		hi, err := u.Next(bits - 32)
+		return lo | hi<<32, err
+	}
+	for u.n < uint(bits) {
+		if u.pos == len(u.data) {
+			return 0, errBitUnderflow
+		}
+		u.acc |= uint64(u.data[u.pos]) << u.n
+		u.pos++
+		u.n += 8
+	}
+	v := u.acc & (1<<bits - 1)
+	u.acc >>= bits
+	u.n -= uint(bits)
+	return v, nil
+}
+
+// --- Baselines ---
+
+// appendFullBytes stores each value in the whole number of bytes its width
+// needs, little-endian: a 12-bit value takes two bytes.
+func appendFullBytes(dst []byte, value uint64, bits int) []byte {
+	for i := 0; i < (bits+7)/8; i++ {
+		dst = append(dst, byte(value>>(8*i)))
+	}
+	return dst
+}
+
+// packBatchAlloc packs one batch into a new buffer that grows from empty.
+func packBatchAlloc(values []uint64, widths []int) []byte {
+	var p BitPacker
+	for i, v := range values {
+		p.Append(v, widths[i%len(widths)])
+	}
+	return p.Bytes()
+}
+
+func TestBitPackerLayout(t *testing.T) {
+	var p BitPacker
+	p.Append(0b101, 3)
+	p.Append(0b11111, 5)
+	p.Append(0xabc, 12) // crosses into a third byte
+	if got, want := p.Bytes(), []byte{0xfd, 0xbc, 0x0a}; !bytes.Equal(got, want) {
+		t.Fatalf("packed % x, want % x", got, want)
+	}
+	p.Append(0xf, 4)
+	if got, want := p.Bytes(), []byte{0xfd, 0xbc, 0xfa}; !bytes.Equal(got, want) {
+		t.Fatalf("after filling the partial byte: % x, want % x", got, want)
+	}
+}
+
+func TestBitPackerRoundTripEveryWidth(t *testing.T) {
+	rng := rand.New(rand.NewPCG(41, 41))
+	p := NewBitPacker(0)
+	u := NewBitUnpacker(nil)
+	for bits := 1; bits <= 64; bits++ {
+		// The 3-bit prefix starts the values off a byte boundary, and odd
+		// widths then cycle through every offset within a byte.
+		values := make([]uint64, 101)
+		for i := range values {
+			values[i] = rng.Uint64() >> (64 - bits)
+		}
+		values[0], values[1] = 0, 1<<bits-1 // zero and all ones
+		if bits == 64 {
+			values[1] = ^uint64(0)
+		}
+		p.Reset()
+		p.Append(0b110, 3)
+		for _, v := range values {
+			p.Append(v, bits)
+		}
+		if want := (3 + 101*bits + 7) / 8; len(p.Bytes()) != want {
+			t.Fatalf("width %d: %d bytes, want %d", bits, len(p.Bytes()), want)
+		}
+		u.Reset(p.Bytes())
+		if prefix, _ := u.Next(3); prefix != 0b110 {
+			t.Fatalf("width %d: prefix %b", bits, prefix)
+		}
+		for i, want := range values {
+			got, err := u.Next(bits)
+			if err != nil || got != want {
+				t.Fatalf("width %d value %d: got %#x, %v; want %#x", bits, i, got, err, want)
+			}
+		}
+	}
+}
+
+func TestBitPackerRoundTripMixedWidths(t *testing.T) {
+	rng := rand.New(rand.NewPCG(43, 43))
+	widths := make([]int, 10_000)
+	values := make([]uint64, len(widths))
+	var p BitPacker
+	for i := range widths {
+		widths[i] = 1 + rng.IntN(64)
+		values[i] = rng.Uint64() >> (64 - widths[i])
+		p.Append(values[i], widths[i])
+	}
+	u := NewBitUnpacker(p.Bytes())
+	for i, bits := range widths {
+		if got, err := u.Next(bits); err != nil || got != values[i] {
+			t.Fatalf("value %d (%d bits): got %#x, %v; want %#x", i, bits, got, err, values[i])
+		}
+	}
+	// Only the zero padding of the last byte is left.
+	if _, err := u.Next(8); !errors.Is(err, errBitUnderflow) {
+		t.Fatalf("reading past the end: %v, want errBitUnderflow", err)
+	}
+}
+
+func TestBitPackerResetClearsPendingBits(t *testing.T) {
+	p := NewBitPacker(0)
+	for i := 0; i < 1000; i++ {
+		p.Append(1<<11-1, 11) // leaves pending ones after the last full byte
+	}
+	p.Reset()
+	p.Append(0b01, 2)
+	if got := p.Bytes(); !bytes.Equal(got, []byte{0b01}) {
+		t.Fatalf("after Reset: % x, want 01", got)
+	}
+	if got, want := packBatchAlloc(bitPackBenchValues[:4096], bitPackWidths), bitPackBatch(p, bitPackBenchValues[:4096]); !bytes.Equal(got, want) {
+		t.Fatal("a reused packer's output differs from a fresh one")
+	}
+}
+
+func TestBitPackerPanicsOnBadInput(t *testing.T) {
+	for _, c := range []struct {
+		value uint64
+		bits  int
+	}{{0, 0}, {0, 65}, {16, 4}, {1 << 56, 56}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Append(%#x, %d) did not panic", c.value, c.bits)
+				}
+			}()
+			var p BitPacker
+			p.Append(c.value, c.bits)
+		}()
+	}
+}
+
+func TestBitPackerReusedDoesNotAllocate(t *testing.T) {
+	p := NewBitPacker(0)
+	bitPackBatch(p, bitPackBenchValues[:4096])
+	allocs := testing.AllocsPerRun(100, func() {
+		bitPackLenSink = len(bitPackBatch(p, bitPackBenchValues[:4096]))
+	})
+	if allocs != 0 {
+		t.Fatalf("reused packer allocated %v times per batch", allocs)
+	}
+}
+
+// bitPackWidths is the record layout of a sensor reading: a 4-bit channel,
+// a 12-bit ADC sample, a 7-bit status, and a 9-bit delta, 32 bits in all.
+var bitPackWidths = []int{4, 12, 7, 9}
+
+// bitPackBenchValues are 4M values in that layout, packed in batches of
+// 4096.
+const (
+	bitPackValues = 1 << 22
+	bitPackBatchN = 4096
+)
+
+var bitPackBenchValues = func() []uint64 {
+	rng := rand.New(rand.NewPCG(47, 47))
+	out := make([]uint64, bitPackValues)
+	for i := range out {
+		out[i] = rng.Uint64() >> (64 - bitPackWidths[i%len(bitPackWidths)])
+	}
+	return out
+}()
+
+var bitPackLenSink int
+
+// bitPackBatch packs one batch with a reused packer.
+func bitPackBatch(p *BitPacker, values []uint64) []byte {
+	p.Reset()
+	for i, v := range values {
+		p.Append(v, bitPackWidths[i%len(bitPackWidths)])
+	}
+	return p.Bytes()
+}
+
+// Each operation packs all 4M values. ratio is the size of the full-byte
+// encoding divided by the size of the output.
+
+func BenchmarkBitPackFullBytes(b *testing.B) {
+	buf := make([]byte, 0, 2*bitPackBatchN)
+	total := 0
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		total = 0
+		for off := 0; off < bitPackValues; off += bitPackBatchN {
+			buf = buf[:0]
+			for j, v := range bitPackBenchValues[off : off+bitPackBatchN] {
+				buf = appendFullBytes(buf, v, bitPackWidths[j%len(bitPackWidths)])
+			}
+			total += len(buf)
+		}
+		bitPackLenSink = total
+	}
+	b.ReportMetric(1, "ratio")
+}
+
+func BenchmarkBitPackAllocPerBatch(b *testing.B) {
+	total := 0
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		total = 0
+		for off := 0; off < bitPackValues; off += bitPackBatchN {
+			total += len(packBatchAlloc(bitPackBenchValues[off:off+bitPackBatchN], bitPackWidths))
+		}
+		bitPackLenSink = total
+	}
+	b.ReportMetric(float64(bitPackFullBytesLen())/float64(total), "ratio")
+}
+
+func BenchmarkBitPackReused(b *testing.B) {
+	p := NewBitPacker(bitPackBatchN * 4)
+	total := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		total = 0
+		for off := 0; off < bitPackValues; off += bitPackBatchN {
+			total += len(bitPackBatch(p, bitPackBenchValues[off:off+bitPackBatchN]))
+		}
+		bitPackLenSink = total
+	}
+	b.ReportMetric(float64(bitPackFullBytesLen())/float64(total), "ratio")
+}
+
+func BenchmarkBitUnpackReused(b *testing.B) {
+	packed := make([][]byte, 0, bitPackValues/bitPackBatchN)
+	p := NewBitPacker(0)
+	for off := 0; off < bitPackValues; off += bitPackBatchN {
+		packed = append(packed, bytes.Clone(bitPackBatch(p, bitPackBenchValues[off:off+bitPackBatchN])))
+	}
+	u := NewBitUnpacker(nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var sum uint64
+		for _, batch := range packed {
+			u.Reset(batch)
+			for j := 0; j < bitPackBatchN; j++ {
+				v, err := u.Next(bitPackWidths[j%len(bitPackWidths)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				sum += v
+			}
+		}
+		bitPackLenSink = int(sum)
+	}
+}
+
+func bitPackFullBytesLen() int {
+	n := 0
+	for i := range bitPackBenchValues {
+		n += (bitPackWidths[i%len(bitPackWidths)] + 7) / 8
+	}
+	return n
+}
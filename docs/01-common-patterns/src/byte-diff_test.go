@@ -0,0 +1,217 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+)
+
+const (
+	diffMinMatch  = 4
+	diffTableBits = 12
+)
+
+// PatchOp either copies Len bytes from the source at Off, or inserts Len
+// bytes from Patch.Literals at Off.
+type PatchOp struct {
+	Copy bool
+	Off  int32
+	Len  int32
+}
+
+type Patch struct {
+	Ops      []PatchOp
+	Literals []byte
+}
+
+// Differ computes byte-level patches. Its hash table, op list, and literal
+// buffer are reused between calls, so the Patch returned by Diff is only
+// valid until the next call.
+type Differ struct {
+	table []int32 // hash of 4 source bytes -> source offset + 1
+	patch Patch
+}
+
+func diffHash(b []byte) uint32 {
+	return binary.LittleEndian.Uint32(b) * 2654435761 >> (32 - diffTableBits)
+}
+
+func (d *Differ) Diff(src, dst []byte) *Patch {
+	if d.table == nil {
+		d.table = make([]int32, 1<<diffTableBits)
+	} else {
+		clear(d.table)
+	}
+	for i := 0; i+diffMinMatch <= len(src); i++ {
+		d.table[diffHash(src[i:])] = int32(i + 1)
+	}
+
+	p := &d.patch
+	p.Ops, p.Literals = p.Ops[:0], p.Literals[:0]
+	pending := 0 // start of literals not yet covered by an insert op
+	flush := func() {
+		if n := len(p.Literals) - pending; n > 0 {
+			p.Ops = append(p.Ops, PatchOp{Off: int32(pending), Len: int32(n)})
+			pending = len(p.Literals)
+		}
+	}
+
+	for i := 0; i < len(dst); {
+		if i+diffMinMatch <= len(dst) {
+			if s := d.table[diffHash(dst[i:])] - 1; s >= 0 {
+				n := commonPrefixLen(src[s:], dst[i:])
+				if n >= diffMinMatch {
+					flush()
+					p.Ops = append(p.Ops, PatchOp{Copy: true, Off: s, Len: int32(n)})
+					i += n
+					continue
+				}
+			}
+		}
+		p.Literals = append(p.Literals, dst[i])
+		i++
+	}
+	flush()
+	return p
+}
+
+func commonPrefixLen(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// diffFresh allocates a new hash table and patch buffers for every call.
+func diffFresh(src, dst []byte) *Patch {
+	var d Differ
+	return d.Diff(src, dst)
+}
+
+// ApplyPatch appends the result of applying p to src onto out.
+func ApplyPatch(out, src []byte, p *Patch) []byte {
+	for _, op := range p.Ops {
+		if op.Copy {
+			out = append(out, src[op.Off:op.Off+op.Len]...)
+		} else {
+			out = append(out, p.Literals[op.Off:op.Off+op.Len]...)
+		}
+	}
+	return out
+}
+
+type diffPair struct {
+	src, dst []byte
+}
+
+// mutatedPairs creates sources of random text and targets derived from them
+// with insertions, deletions, and overwrites.
+func mutatedPairs(count, size int, seed uint64) []diffPair {
+	rng := rand.New(rand.NewPCG(seed, seed^0xabcdef))
+	const alphabet = "abcdefghijklmnopqrstuvwxyz     \n"
+	pairs := make([]diffPair, count)
+	for i := range pairs {
+		src := make([]byte, size)
+		for j := range src {
+			src[j] = alphabet[rng.IntN(len(alphabet))]
+		}
+		dst := bytes.Clone(src)
+		for e := 0; e < 4; e++ {
+			at := rng.IntN(len(dst) + 1)
+			switch rng.IntN(3) {
+			case 0:
+				dst = append(dst[:at], append([]byte("INSERTED"), dst[at:]...)...)
+			case 1:
+				end := min(at+rng.IntN(32), len(dst))
+				dst = append(dst[:at], dst[end:]...)
+			case 2:
+				for j := at; j < min(at+8, len(dst)); j++ {
+					dst[j] = 'X'
+				}
+			}
+		}
+		pairs[i] = diffPair{src, dst}
+	}
+	return pairs
+}
+
+func TestDiffApplyReconstructsTarget(t *testing.T) {
+	pairs := mutatedPairs(200, 512, 1)
+	pairs = append(pairs,
+		diffPair{nil, nil},
+		diffPair{nil, []byte("all new")},
+		diffPair{[]byte("everything removed"), nil},
+		diffPair{[]byte("identical input"), []byte("identical input")},
+		diffPair{[]byte("abc"), []byte("abcabcabc")},
+		diffPair{bytes.Repeat([]byte("a"), 1000), bytes.Repeat([]byte("a"), 1500)},
+	)
+
+	var d Differ
+	var out []byte
+	for i, pair := range pairs {
+		for name, p := range map[string]*Patch{"reused": d.Diff(pair.src, pair.dst), "fresh": diffFresh(pair.src, pair.dst)} {
+			out = ApplyPatch(out[:0], pair.src, p)
+			if !bytes.Equal(out, pair.dst) {
+				t.Fatalf("pair %d (%s): patch produced %q, want %q", i, name, out, pair.dst)
+			}
+		}
+	}
+}
+
+func TestDiffUsesCopies(t *testing.T) {
+	src := []byte("the quick brown fox jumps over the lazy dog")
+	dst := []byte("the quick red fox jumps over the lazy dog!")
+	p := diffFresh(src, dst)
+	copied := 0
+	for _, op := range p.Ops {
+		if op.Copy {
+			copied += int(op.Len)
+		}
+	}
+	if copied < len(dst)/2 {
+		t.Fatalf("only %d of %d bytes copied from source: %+v", copied, len(dst), p.Ops)
+	}
+}
+
+func TestDifferReusesScratch(t *testing.T) {
+	pairs := mutatedPairs(16, 512, 2)
+	var d Differ
+	for _, pair := range pairs {
+		d.Diff(pair.src, pair.dst) // warm up the buffers
+	}
+	i := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		pair := pairs[i%len(pairs)]
+		d.Diff(pair.src, pair.dst)
+		i++
+	})
+	if allocs != 0 {
+		t.Fatalf("Diff allocated %v times with warm scratch", allocs)
+	}
+}
+
+var (
+	diffBenchPairs = mutatedPairs(1000, 512, 3)
+	patchSink      *Patch
+)
+
+func BenchmarkDiffFreshBuffers(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, pair := range diffBenchPairs {
+			patchSink = diffFresh(pair.src, pair.dst)
+		}
+	}
+}
+
+func BenchmarkDiffReusedBuffers(b *testing.B) {
+	var d Differ
+	for i := 0; i < b.N; i++ {
+		for _, pair := range diffBenchPairs {
+			patchSink = d.Diff(pair.src, pair.dst)
+		}
+	}
+}
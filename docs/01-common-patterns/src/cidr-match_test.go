@@ -0,0 +1,284 @@
+package perf
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// cidrMatcher reports whether an address, given as text the way it arrives
+// in a request or log line, falls inside any prefix of a set.
+type cidrMatcher interface {
+	Contains(addr string) bool
+}
+
+// --- net.IPNet: parse to a net.IP, scan every network ---
+
+type netCIDRSet []*net.IPNet
+
+func newNetCIDRSet(cidrs []string) netCIDRSet {
+	set := make(netCIDRSet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		set[i] = n
+	}
+	return set
+}
+
+func (s netCIDRSet) Contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range s {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- netip.Prefix: parse to a netip.Addr, scan every prefix ---
+
+type prefixCIDRSet []netip.Prefix
+
+func newPrefixCIDRSet(cidrs []string) prefixCIDRSet {
+	set := make(prefixCIDRSet, len(cidrs))
+	for i, c := range cidrs {
+		set[i] = netip.MustParsePrefix(c).Masked()
+	}
+	return set
+}
+
+func (s prefixCIDRSet) Contains(addr string) bool {
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	a = a.Unmap() // match net.IPNet, which treats ::ffff:a.b.c.d as IPv4
+	for _, p := range s {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Precomputed sorted ranges with binary search ---
+
+type addrRange struct {
+	first, last netip.Addr
+}
+
+// rangeCIDRSet holds the prefixes as sorted, non-overlapping address
+// ranges. netip.Addr.Compare orders IPv4 before IPv6, so both families
+// share one slice without ever overlapping.
+type rangeCIDRSet []addrRange
+
+// prefixLast returns the highest address in p, its broadcast address for
+// IPv4.
+func prefixLast(p netip.Prefix) netip.Addr {
+	a := p.Addr().As16()
+	bits := p.Bits()
+	if p.Addr().Is4() {
+		bits += 96
+	}
+	for i := bits; i < 128; i++ {
+		a[i/8] |= 1 << (7 - i%8)
+	}
+	last := netip.AddrFrom16(a)
+	if p.Addr().Is4() {
+		last = last.Unmap()
+	}
+	return last
+}
+
+func newRangeCIDRSet(cidrs []string) rangeCIDRSet {
+	ranges := make([]addrRange, 0, len(cidrs))
+	for _, c := range cidrs {
+		p := netip.MustParsePrefix(c).Masked()
+		ranges = append(ranges, addrRange{first: p.Addr(), last: prefixLast(p)})
+	}
+	slices.SortFunc(ranges, func(a, b addrRange) int { return a.first.Compare(b.first) })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.first.Compare(merged[n-1].last) <= 0 {
+			if r.last.Compare(merged[n-1].last) > 0 {
+				merged[n-1].last = r.last
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return rangeCIDRSet(merged)
+}
+
+func (s rangeCIDRSet) Contains(addr string) bool {
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	a = a.Unmap()
+	// Find the first range that starts after a; only the one before it can
+	// contain a.
+	lo, hi := 0, len(s)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if s[mid].first.Compare(a) <= 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo > 0 && a.Compare(s[lo-1].last) <= 0
+}
+
+func cidrMatchers(cidrs []string) map[string]cidrMatcher {
+	return map[string]cidrMatcher{
+		"net.IPNet":    newNetCIDRSet(cidrs),
+		"netip.Prefix": newPrefixCIDRSet(cidrs),
+		"ranges":       newRangeCIDRSet(cidrs),
+	}
+}
+
+func TestCIDRMatchEdgeCases(t *testing.T) {
+	cidrs := []string{"10.0.0.0/8", "192.168.1.0/24", "203.0.113.7/32", "2001:db8::/32", "2001:db8:1::/48"}
+	cases := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.0", true},       // network address
+		{"10.255.255.255", true}, // broadcast address
+		{"9.255.255.255", false}, // just below
+		{"11.0.0.0", false},      // just above
+		{"192.168.1.0", true},    // network address
+		{"192.168.1.255", true},  // broadcast address
+		{"192.168.2.0", false},   // next /24
+		{"203.0.113.7", true},    // the /32 itself
+		{"203.0.113.6", false},   // its neighbors
+		{"203.0.113.8", false},   //
+		{"2001:db8::", true},     // first IPv6 address
+		{"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", true},
+		{"2001:db9::", false},
+		{"::ffff:10.1.2.3", true}, // IPv4-mapped IPv6
+		{"::a01:203", false},      // 10.1.2.3 in IPv4-compatible form is IPv6
+		{"not an address", false},
+	}
+	for name, m := range cidrMatchers(cidrs) {
+		for _, c := range cases {
+			if got := m.Contains(c.addr); got != c.want {
+				t.Errorf("%s: Contains(%q) = %t, want %t", name, c.addr, got, c.want)
+			}
+		}
+	}
+}
+
+func TestCIDRMatchZeroPrefix(t *testing.T) {
+	for name, m := range cidrMatchers([]string{"0.0.0.0/0"}) {
+		for _, addr := range []string{"0.0.0.0", "127.0.0.1", "255.255.255.255"} {
+			if !m.Contains(addr) {
+				t.Errorf("%s: 0.0.0.0/0 should contain %s", name, addr)
+			}
+		}
+		if m.Contains("::1") {
+			t.Errorf("%s: 0.0.0.0/0 should not contain an IPv6 address", name)
+		}
+	}
+	for name, m := range cidrMatchers([]string{"::/0"}) {
+		if !m.Contains("::") || !m.Contains("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff") {
+			t.Errorf("%s: ::/0 should contain every IPv6 address", name)
+		}
+	}
+}
+
+func TestCIDRMatchersAgreeOnRandomSets(t *testing.T) {
+	rng := rand.New(rand.NewPCG(31, 37))
+	for set := 0; set < 20; set++ {
+		// Few distinct first octets, so prefixes nest and overlap.
+		cidrs := randomCIDRs(rng, 100, 4)
+		ms := cidrMatchers(cidrs)
+		for i := 0; i < 2000; i++ {
+			addr := randomProbe(rng, 4)
+			want := ms["net.IPNet"].Contains(addr)
+			for name, m := range ms {
+				if got := m.Contains(addr); got != want {
+					t.Fatalf("set %d: %s.Contains(%s) = %t, net.IPNet says %t (prefixes %v)", set, name, addr, got, want, cidrs)
+				}
+			}
+		}
+	}
+}
+
+func TestCIDRMatchNetipDoesNotAllocate(t *testing.T) {
+	cidrs := randomCIDRs(rand.New(rand.NewPCG(1, 1)), 50, 256)
+	for name, m := range cidrMatchers(cidrs) {
+		if name == "net.IPNet" {
+			continue
+		}
+		if allocs := testing.AllocsPerRun(100, func() { m.Contains("198.51.100.23") }); allocs != 0 {
+			t.Errorf("%s allocated %v times per check", name, allocs)
+		}
+	}
+}
+
+// randomCIDRs returns n prefixes, mostly IPv4, whose first octet is drawn
+// from [1, 1+spread).
+func randomCIDRs(rng *rand.Rand, n, spread int) []string {
+	out := make([]string, n)
+	for i := range out {
+		if i%5 == 4 {
+			out[i] = fmt.Sprintf("2001:db8:%x::/%d", rng.IntN(spread), 32+rng.IntN(33))
+		} else {
+			out[i] = fmt.Sprintf("%d.%d.%d.0/%d", 1+rng.IntN(spread), rng.IntN(256), rng.IntN(256), 8+rng.IntN(25))
+		}
+	}
+	return out
+}
+
+func randomProbe(rng *rand.Rand, spread int) string {
+	if rng.IntN(5) == 0 {
+		return fmt.Sprintf("2001:db8:%x:%x::%x", rng.IntN(spread), rng.IntN(1<<16), rng.IntN(1<<16))
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", 1+rng.IntN(spread), rng.IntN(256), rng.IntN(256), rng.IntN(256))
+}
+
+// Benchmarks check 10,000 client addresses per operation against 500
+// prefixes, the size of a typical allowlist or cloud-provider range list.
+var (
+	cidrBenchRNG    = rand.New(rand.NewPCG(41, 43))
+	cidrBenchSet    = randomCIDRs(cidrBenchRNG, 500, 223)
+	cidrBenchProbes = func() []string {
+		out := make([]string, 10_000)
+		for i := range out {
+			out[i] = randomProbe(cidrBenchRNG, 223)
+		}
+		return out
+	}()
+	cidrHitSink int
+)
+
+func benchmarkCIDR(b *testing.B, m cidrMatcher) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hits := 0
+		for _, addr := range cidrBenchProbes {
+			if m.Contains(addr) {
+				hits++
+			}
+		}
+		cidrHitSink = hits
+	}
+}
+
+func BenchmarkCIDRNetIPNet(b *testing.B) { benchmarkCIDR(b, newNetCIDRSet(cidrBenchSet)) }
+
+func BenchmarkCIDRNetipPrefix(b *testing.B) { benchmarkCIDR(b, newPrefixCIDRSet(cidrBenchSet)) }
+
+func BenchmarkCIDRSortedRanges(b *testing.B) { benchmarkCIDR(b, newRangeCIDRSet(cidrBenchSet)) }
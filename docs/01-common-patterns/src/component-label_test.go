@@ -0,0 +1,246 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// The graph types below are the CSR layout from csr-graph_test.go, repeated
+// so that this file stands alone.
+
+type graphEdge struct {
+	from, to int32
+}
+
+// CSRGraph stores adjacency in compressed sparse row form: the neighbors of
+// node v are targets[offsets[v]:offsets[v+1]].
+type CSRGraph struct {
+	offsets []int32
+	targets []int32
+}
+
+func BuildCSR(numNodes int, edges []graphEdge) *CSRGraph {
+	offsets := make([]int32, numNodes+1)
+	for _, e := range edges {
+		offsets[e.from+1]++
+	}
+	for v := 1; v <= numNodes; v++ {
+		offsets[v] += offsets[v-1]
+	}
+	targets := make([]int32, len(edges))
+	cursor := make([]int32, numNodes)
+	copy(cursor, offsets[:numNodes])
+	for _, e := range edges {
+		targets[cursor[e.from]] = e.to
+		cursor[e.from]++
+	}
+	return &CSRGraph{offsets: offsets, targets: targets}
+}
+
+func (g *CSRGraph) Neighbors(v int32) []int32 {
+	return g.targets[g.offsets[v]:g.offsets[v+1]]
+}
+
+func (g *CSRGraph) NumNodes() int { return len(g.offsets) - 1 }
+
+func randomEdges(numNodes, numEdges int, seed uint64) []graphEdge {
+	rng := rand.New(rand.NewPCG(seed, seed*31))
+	edges := make([]graphEdge, numEdges)
+	for i := range edges {
+		edges[i] = graphEdge{from: rng.Int32N(int32(numNodes)), to: rng.Int32N(int32(numNodes))}
+	}
+	return edges
+}
+
+// undirectedCSR builds a CSR graph with every edge stored in both directions.
+func undirectedCSR(numNodes int, edges []graphEdge) *CSRGraph {
+	both := make([]graphEdge, 0, 2*len(edges))
+	for _, e := range edges {
+		both = append(both, e, graphEdge{from: e.to, to: e.from})
+	}
+	return BuildCSR(numNodes, both)
+}
+
+// labelComponents assigns each node a 1-based component label using an
+// iterative DFS. labels must be zeroed and sized to the graph; stack is
+// scratch space. It returns the number of components and the stack, which
+// may have grown.
+func labelComponents(g *CSRGraph, labels, stack []int32) (int, []int32) {
+	next := int32(0)
+	for start := range labels {
+		if labels[start] != 0 {
+			continue
+		}
+		next++
+		labels[start] = next
+		stack = append(stack[:0], int32(start))
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, u := range g.Neighbors(v) {
+				if labels[u] == 0 {
+					labels[u] = next
+					stack = append(stack, u)
+				}
+			}
+		}
+	}
+	return int(next), stack
+}
+
+// labelFresh allocates new label and stack arrays for every run.
+func labelFresh(g *CSRGraph) ([]int32, int) {
+	labels := make([]int32, g.NumNodes())
+	n, _ := labelComponents(g, labels, nil)
+	return labels, n
+}
+
+// componentLabeler reuses its arrays across runs. Labels returned by Label
+// are valid until the next call.
+type componentLabeler struct {
+	labels []int32
+	stack  []int32
+}
+
+func (l *componentLabeler) Label(g *CSRGraph) ([]int32, int) {
+	n := g.NumNodes()
+	if cap(l.labels) < n {
+		l.labels = make([]int32, n)
+	}
+	l.labels = l.labels[:n]
+	clear(l.labels)
+	var count int
+	count, l.stack = labelComponents(g, l.labels, l.stack)
+	return l.labels, count
+}
+
+// unionFindComponents is an independent reference for the component count.
+func unionFindComponents(numNodes int, edges []graphEdge) int {
+	parent := make([]int32, numNodes)
+	for i := range parent {
+		parent[i] = int32(i)
+	}
+	var find func(int32) int32
+	find = func(x int32) int32 {
+		for parent[x] != x {
+			parent[x] = parent[parent[x]]
+			x = parent[x]
+		}
+		return x
+	}
+	count := numNodes
+	for _, e := range edges {
+		if a, b := find(e.from), find(e.to); a != b {
+			parent[a] = b
+			count--
+		}
+	}
+	return count
+}
+
+func checkLabels(t *testing.T, edges []graphEdge, labels []int32, count, want int) {
+	t.Helper()
+	if count != want {
+		t.Fatalf("found %d components, union-find found %d", count, want)
+	}
+	for _, e := range edges {
+		if labels[e.from] != labels[e.to] {
+			t.Fatalf("edge %d-%d crosses components %d and %d", e.from, e.to, labels[e.from], labels[e.to])
+		}
+	}
+	for v, l := range labels {
+		if l < 1 || int(l) > count {
+			t.Fatalf("node %d has label %d, want 1..%d", v, l, count)
+		}
+	}
+}
+
+func TestComponentLabels(t *testing.T) {
+	// Two triangles and an isolated node.
+	edges := []graphEdge{{0, 1}, {1, 2}, {2, 0}, {3, 4}, {4, 5}, {5, 3}}
+	g := undirectedCSR(7, edges)
+	labels, n := labelFresh(g)
+	want := []int32{1, 1, 1, 2, 2, 2, 3}
+	if n != 3 {
+		t.Fatalf("found %d components, want 3", n)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Fatalf("labels = %v, want %v", labels, want)
+		}
+	}
+}
+
+func TestComponentLabelerReuseMatchesFresh(t *testing.T) {
+	var l componentLabeler
+	// Varying sizes make the labeler shrink and grow its view of the array;
+	// stale labels from a larger graph must not leak into a smaller one.
+	for i, size := range []int{5000, 800, 12000, 3000, 3000} {
+		edges := randomEdges(size, size*3/4, uint64(i+1))
+		g := undirectedCSR(size, edges)
+		want := unionFindComponents(size, edges)
+
+		fresh, nFresh := labelFresh(g)
+		checkLabels(t, edges, fresh, nFresh, want)
+
+		reused, nReused := l.Label(g)
+		checkLabels(t, edges, reused, nReused, want)
+		for v := range fresh {
+			if fresh[v] != reused[v] {
+				t.Fatalf("graph %d node %d: reused label %d, fresh %d", i, v, reused[v], fresh[v])
+			}
+		}
+	}
+}
+
+func TestComponentLabelerDoesNotAllocate(t *testing.T) {
+	g := undirectedCSR(2000, randomEdges(2000, 1500, 7))
+	var l componentLabeler
+	l.Label(g)
+	if allocs := testing.AllocsPerRun(100, func() { l.Label(g) }); allocs != 0 {
+		t.Fatalf("reused labeler allocated %v times", allocs)
+	}
+}
+
+const (
+	labelGraphCount = 1000
+	labelGraphNodes = 500
+)
+
+var (
+	labelGraphs     = makeLabelGraphs()
+	componentsTotal int
+)
+
+func makeLabelGraphs() []*CSRGraph {
+	graphs := make([]*CSRGraph, labelGraphCount)
+	for i := range graphs {
+		graphs[i] = undirectedCSR(labelGraphNodes, randomEdges(labelGraphNodes, labelGraphNodes*3/4, uint64(100+i)))
+	}
+	return graphs
+}
+
+func BenchmarkComponentLabelFresh(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		total := 0
+		for _, g := range labelGraphs {
+			_, n := labelFresh(g)
+			total += n
+		}
+		componentsTotal = total
+	}
+}
+
+func BenchmarkComponentLabelReused(b *testing.B) {
+	var l componentLabeler
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		total := 0
+		for _, g := range labelGraphs {
+			_, n := l.Label(g)
+			total += n
+		}
+		componentsTotal = total
+	}
+}
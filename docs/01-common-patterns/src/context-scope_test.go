@@ -0,0 +1,189 @@
+package perf
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type scopeKey int
+
+const (
+	keyRequestID scopeKey = iota
+	keyUserID
+	keyTenant
+	keyTraceID
+	keyLocale
+	keyScope
+)
+
+// incomingRequest stands in for whatever the transport layer decoded.
+type incomingRequest struct {
+	RequestID string
+	UserID    int64
+	Tenant    string
+	TraceID   [16]byte
+	Locale    string
+}
+
+var demoRequest = incomingRequest{
+	RequestID: "req-7f3a9c",
+	UserID:    912_334,
+	Tenant:    "acme",
+	TraceID:   [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+	Locale:    "en-US",
+}
+
+// --- Five context.WithValue calls ---
+
+// Each WithValue allocates a new context node, and each non-pointer value is
+// boxed into an interface on the way in.
+func attachWithValues(ctx context.Context, r *incomingRequest) context.Context {
+	ctx = context.WithValue(ctx, keyRequestID, r.RequestID)
+	ctx = context.WithValue(ctx, keyUserID, r.UserID)
+	ctx = context.WithValue(ctx, keyTenant, r.Tenant)
+	ctx = context.WithValue(ctx, keyTraceID, r.TraceID)
+	ctx = context.WithValue(ctx, keyLocale, r.Locale)
+	return ctx
+}
+
+func handleWithValues(ctx context.Context) int {
+	n := len(ctx.Value(keyRequestID).(string))
+	n += int(ctx.Value(keyUserID).(int64) & 0xff)
+	n += len(ctx.Value(keyTenant).(string))
+	n += int(ctx.Value(keyTraceID).([16]byte)[0])
+	n += len(ctx.Value(keyLocale).(string))
+	return n
+}
+
+// --- One pooled request-scoped struct ---
+
+// RequestScope groups everything a request carries through the handler chain.
+type RequestScope struct {
+	RequestID string
+	UserID    int64
+	Tenant    string
+	TraceID   [16]byte
+	Locale    string
+}
+
+var requestScopePool = sync.Pool{
+	New: func() any {
+		return new(RequestScope)
+	},
+}
+
+func acquireRequestScope() *RequestScope {
+	return requestScopePool.Get().(*RequestScope)
+}
+
+// releaseRequestScope zeroes the scope before returning it to the pool so
+// that no request can observe values left behind by a previous one.
+func releaseRequestScope(s *RequestScope) {
+	*s = RequestScope{}
+	requestScopePool.Put(s)
+}
+
+func attachScope(s *RequestScope, r *incomingRequest) {
+	s.RequestID = r.RequestID
+	s.UserID = r.UserID
+	s.Tenant = r.Tenant
+	s.TraceID = r.TraceID
+	s.Locale = r.Locale
+}
+
+func handleWithScope(s *RequestScope) int {
+	n := len(s.RequestID)
+	n += int(s.UserID & 0xff)
+	n += len(s.Tenant)
+	n += int(s.TraceID[0])
+	n += len(s.Locale)
+	return n
+}
+
+// scopeFromContext supports APIs that only accept a context.Context: the
+// pooled pointer is stored once, costing one context node and no boxing.
+func scopeFromContext(ctx context.Context) *RequestScope {
+	s, _ := ctx.Value(keyScope).(*RequestScope)
+	return s
+}
+
+func TestContextValuesAccessible(t *testing.T) {
+	ctx := attachWithValues(context.Background(), &demoRequest)
+	if got := ctx.Value(keyRequestID); got != demoRequest.RequestID {
+		t.Errorf("request id: got %v", got)
+	}
+	if got := ctx.Value(keyUserID); got != demoRequest.UserID {
+		t.Errorf("user id: got %v", got)
+	}
+	if got := ctx.Value(keyTenant); got != demoRequest.Tenant {
+		t.Errorf("tenant: got %v", got)
+	}
+	if got := ctx.Value(keyTraceID); got != demoRequest.TraceID {
+		t.Errorf("trace id: got %v", got)
+	}
+	if got := ctx.Value(keyLocale); got != demoRequest.Locale {
+		t.Errorf("locale: got %v", got)
+	}
+}
+
+func TestRequestScopeAccessible(t *testing.T) {
+	s := acquireRequestScope()
+	defer releaseRequestScope(s)
+	attachScope(s, &demoRequest)
+
+	ctx := context.WithValue(context.Background(), keyScope, s)
+	got := scopeFromContext(ctx)
+	if got != s {
+		t.Fatal("scope pointer not recovered from context")
+	}
+	if *got != RequestScope(demoRequest) {
+		t.Errorf("scope = %+v, want %+v", *got, demoRequest)
+	}
+	if a, b := handleWithScope(s), handleWithValues(attachWithValues(context.Background(), &demoRequest)); a != b {
+		t.Errorf("handlers disagree: %d vs %d", a, b)
+	}
+}
+
+func TestRequestScopeResetBetweenRequests(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		s := acquireRequestScope()
+		if *s != (RequestScope{}) {
+			t.Fatalf("iteration %d: pooled scope leaked data: %+v", i, *s)
+		}
+		r := demoRequest
+		r.UserID = int64(i)
+		attachScope(s, &r)
+		releaseRequestScope(s)
+	}
+}
+
+var scopeSink int
+
+func BenchmarkContextWithValues(b *testing.B) {
+	base := context.Background()
+	for i := 0; i < b.N; i++ {
+		ctx := attachWithValues(base, &demoRequest)
+		scopeSink = handleWithValues(ctx)
+	}
+}
+
+func BenchmarkContextPooledScopeInContext(b *testing.B) {
+	base := context.Background()
+	for i := 0; i < b.N; i++ {
+		s := acquireRequestScope()
+		attachScope(s, &demoRequest)
+		ctx := context.WithValue(base, keyScope, s)
+		scopeSink = handleWithScope(scopeFromContext(ctx))
+		releaseRequestScope(s)
+	}
+}
+
+func BenchmarkContextPooledScopeParam(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		s := acquireRequestScope()
+		attachScope(s, &demoRequest)
+		scopeSink = handleWithScope(s)
+		releaseRequestScope(s)
+	}
+}
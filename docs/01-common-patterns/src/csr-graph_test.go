@@ -0,0 +1,135 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+type graphEdge struct {
+	from, to int32
+}
+
+// CSRGraph stores adjacency in compressed sparse row form: the neighbors of
+// node v are targets[offsets[v]:offsets[v+1]]. Two flat arrays replace one
+// slice per node.
+type CSRGraph struct {
+	offsets []int32
+	targets []int32
+}
+
+// BuildCSR constructs a CSRGraph in two passes over the edges. The first pass
+// counts out-degrees to compute exact offsets; the second places each target
+// into its slot. Edge order per node is preserved.
+func BuildCSR(numNodes int, edges []graphEdge) *CSRGraph {
+	offsets := make([]int32, numNodes+1)
+	for _, e := range edges {
+		offsets[e.from+1]++
+	}
+	for v := 1; v <= numNodes; v++ {
+		offsets[v] += offsets[v-1]
+	}
+
+	targets := make([]int32, len(edges))
+	cursor := make([]int32, numNodes)
+	copy(cursor, offsets[:numNodes])
+	for _, e := range edges {
+		targets[cursor[e.from]] = e.to
+		cursor[e.from]++
+	}
+	return &CSRGraph{offsets: offsets, targets: targets}
+}
+
+func (g *CSRGraph) Neighbors(v int32) []int32 {
+	return g.targets[g.offsets[v]:g.offsets[v+1]]
+}
+
+func (g *CSRGraph) NumNodes() int { return len(g.offsets) - 1 }
+
+// buildAdjacencyMap is the common approach: one growing slice per node.
+func buildAdjacencyMap(edges []graphEdge) map[int32][]int32 {
+	adj := make(map[int32][]int32)
+	for _, e := range edges {
+		adj[e.from] = append(adj[e.from], e.to)
+	}
+	return adj
+}
+
+func randomEdges(numNodes, numEdges int, seed uint64) []graphEdge {
+	rng := rand.New(rand.NewPCG(seed, seed*31))
+	edges := make([]graphEdge, numEdges)
+	for i := range edges {
+		edges[i] = graphEdge{from: rng.Int32N(int32(numNodes)), to: rng.Int32N(int32(numNodes))}
+	}
+	return edges
+}
+
+func TestCSRNeighborsMatchMap(t *testing.T) {
+	const nodes = 5000
+	edges := randomEdges(nodes, 40_000, 3)
+	edges = append(edges, graphEdge{0, 0}, graphEdge{0, 0}) // self-loop and duplicate
+
+	csr := BuildCSR(nodes, edges)
+	adj := buildAdjacencyMap(edges)
+	for v := int32(0); v < nodes; v++ {
+		if got, want := csr.Neighbors(v), adj[v]; !slices.Equal(got, want) {
+			t.Fatalf("node %d: csr %v, map %v", v, got, want)
+		}
+	}
+	if int(csr.offsets[nodes]) != len(edges) {
+		t.Fatalf("last offset %d, want %d", csr.offsets[nodes], len(edges))
+	}
+}
+
+func TestCSRIsolatedNodes(t *testing.T) {
+	csr := BuildCSR(4, []graphEdge{{1, 2}, {1, 3}})
+	for _, v := range []int32{0, 2, 3} {
+		if n := csr.Neighbors(v); len(n) != 0 {
+			t.Errorf("node %d: unexpected neighbors %v", v, n)
+		}
+	}
+	if n := csr.Neighbors(1); !slices.Equal(n, []int32{2, 3}) {
+		t.Errorf("node 1: neighbors %v", n)
+	}
+}
+
+const (
+	csrNodes = 100_000
+	csrEdges = 1_000_000
+)
+
+var (
+	csrInput   = randomEdges(csrNodes, csrEdges, 1)
+	csrSink    *CSRGraph
+	adjMapSink map[int32][]int32
+)
+
+// retainedHeap reports how much heap the built structure keeps alive.
+func retainedHeap(b *testing.B, build func()) {
+	b.StopTimer()
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc), "retained-B")
+	b.StartTimer()
+}
+
+func BenchmarkAdjacencyMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		adjMapSink = buildAdjacencyMap(csrInput)
+	}
+	adjMapSink = nil
+	retainedHeap(b, func() { adjMapSink = buildAdjacencyMap(csrInput) })
+}
+
+func BenchmarkAdjacencyCSR(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		csrSink = BuildCSR(csrNodes, csrInput)
+	}
+	csrSink = nil
+	retainedHeap(b, func() { csrSink = BuildCSR(csrNodes, csrInput) })
+}
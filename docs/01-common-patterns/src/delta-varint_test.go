@@ -0,0 +1,180 @@
+package perf
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// zigzag maps signed deltas to unsigned values so that small magnitudes of
+// either sign encode to short varints: 0, -1, 1, -2, 2 become 0, 1, 2, 3, 4.
+func zigzag(d int64) uint64 { return uint64(d<<1) ^ uint64(d>>63) }
+
+func unzigzag(u uint64) int64 { return int64(u>>1) ^ -int64(u&1) }
+
+// AppendDeltaVarints appends values to dst as a sequence of zigzag varint
+// deltas, the first taken from zero. Sorted input keeps every delta small;
+// unsorted input still round-trips, it just compresses worse. Deltas wrap
+// around on overflow, and the decoder wraps them back.
+func AppendDeltaVarints(dst []byte, sorted []int64) []byte {
+	prev := int64(0)
+	for _, v := range sorted {
+		dst = binary.AppendUvarint(dst, zigzag(v-prev))
+		prev = v
+	}
+	return dst
+}
+
+var errDeltaVarint = errors.New("delta-varint: truncated or overlong encoding")
+
+// AppendDecodedDeltas decodes every delta in buf and appends the values to
+// dst.
+func AppendDecodedDeltas(dst []int64, buf []byte) ([]int64, error) {
+	prev := int64(0)
+	for len(buf) > 0 {
+		u, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return dst, errDeltaVarint
+		}
+		prev += unzigzag(u)
+		dst = append(dst, prev)
+		buf = buf[n:]
+	}
+	return dst, nil
+}
+
+// encodeDeltasAlloc builds a new slice per sequence.
+func encodeDeltasAlloc(sorted []int64) []byte {
+	var out []byte
+	prev := int64(0)
+	for _, v := range sorted {
+		out = binary.AppendUvarint(out, zigzag(v-prev))
+		prev = v
+	}
+	return out
+}
+
+// appendRawInt64s is the uncompressed baseline: 8 bytes per value.
+func appendRawInt64s(dst []byte, values []int64) []byte {
+	for _, v := range values {
+		dst = binary.LittleEndian.AppendUint64(dst, uint64(v))
+	}
+	return dst
+}
+
+func TestZigzag(t *testing.T) {
+	for d, want := range map[int64]uint64{0: 0, -1: 1, 1: 2, -2: 3, 2: 4, math.MaxInt64: math.MaxUint64 - 1, math.MinInt64: math.MaxUint64} {
+		if got := zigzag(d); got != want {
+			t.Errorf("zigzag(%d) = %d, want %d", d, got, want)
+		}
+		if got := unzigzag(want); got != d {
+			t.Errorf("unzigzag(%d) = %d, want %d", want, got, d)
+		}
+	}
+}
+
+func TestDeltaVarintRoundTrip(t *testing.T) {
+	cases := map[string][]int64{
+		"empty":        {},
+		"single":       {42},
+		"sorted":       {3, 7, 7, 8, 100, 1 << 20, 1 << 40},
+		"negative":     {-1 << 40, -500, -3, 0, 2},
+		"unsorted":     {10, 3, 8, -5, 1 << 50, 0},
+		"extremes":     {math.MinInt64, 0, math.MaxInt64},
+		"reverse wrap": {math.MaxInt64, math.MinInt64},
+		"timestamps":   deltaTimestamps(rand.New(rand.NewPCG(1, 1)), 1000),
+	}
+	for name, values := range cases {
+		enc := AppendDeltaVarints(nil, values)
+		if alloc := encodeDeltasAlloc(values); string(enc) != string(alloc) {
+			t.Fatalf("%s: AppendDeltaVarints and encodeDeltasAlloc disagree", name)
+		}
+		got, err := AppendDecodedDeltas(nil, enc)
+		if err != nil || !slices.Equal(got, values) {
+			t.Fatalf("%s: decoded %v (err %v), want %v", name, got, err, values)
+		}
+	}
+}
+
+func TestDeltaVarintCompressesSortedInput(t *testing.T) {
+	values := deltaTimestamps(rand.New(rand.NewPCG(2, 2)), 1000)
+	enc := AppendDeltaVarints(nil, values)
+	// Millisecond timestamps a few seconds apart: a 6-byte first value, then
+	// at most 2 bytes per delta.
+	if max := 6 + 2*(len(values)-1); len(enc) > max {
+		t.Fatalf("encoded %d sorted timestamps in %d bytes, want at most %d", len(values), len(enc), max)
+	}
+}
+
+func TestDeltaVarintDecodeRejectsTruncated(t *testing.T) {
+	enc := AppendDeltaVarints(nil, []int64{1, 1000, 1 << 30})
+	if _, err := AppendDecodedDeltas(nil, enc[:len(enc)-1]); err != errDeltaVarint {
+		t.Fatalf("truncated input: error %v, want errDeltaVarint", err)
+	}
+}
+
+func TestAppendDeltaVarintsReusesBuffer(t *testing.T) {
+	values := deltaBenchSeqs[0]
+	buf := AppendDeltaVarints(nil, values)
+	out, _ := AppendDecodedDeltas(nil, buf)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = AppendDeltaVarints(buf[:0], values)
+		out, _ = AppendDecodedDeltas(out[:0], buf)
+	})
+	if allocs != 0 {
+		t.Fatalf("encode and decode into reused buffers allocated %v times", allocs)
+	}
+}
+
+// deltaTimestamps returns n sorted millisecond timestamps, 1 ms to 8 s
+// apart, as in an event log or a time-series chunk.
+func deltaTimestamps(rng *rand.Rand, n int) []int64 {
+	out := make([]int64, n)
+	t := int64(1_700_000_000_000) + rng.Int64N(1_000_000_000)
+	for i := range out {
+		t += 1 + rng.Int64N(8000)
+		out[i] = t
+	}
+	return out
+}
+
+var (
+	deltaBenchSeqs = func() [][]int64 {
+		rng := rand.New(rand.NewPCG(7, 7))
+		seqs := make([][]int64, 1000)
+		for i := range seqs {
+			seqs[i] = deltaTimestamps(rng, 1000)
+		}
+		return seqs
+	}()
+	deltaBytesSink int
+)
+
+// Each operation encodes 1,000 sequences of 1,000 timestamps. B/value is the
+// encoded size per timestamp.
+
+func benchmarkDeltaEncode(b *testing.B, encode func(dst []byte, values []int64) []byte) {
+	var buf []byte
+	total := 0
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		total = 0
+		for _, seq := range deltaBenchSeqs {
+			buf = encode(buf[:0], seq)
+			total += len(buf)
+		}
+		deltaBytesSink = total
+	}
+	b.ReportMetric(float64(total)/float64(len(deltaBenchSeqs)*len(deltaBenchSeqs[0])), "B/value")
+}
+
+func BenchmarkDeltaRawInt64(b *testing.B) { benchmarkDeltaEncode(b, appendRawInt64s) }
+
+func BenchmarkDeltaVarintAlloc(b *testing.B) {
+	benchmarkDeltaEncode(b, func(_ []byte, values []int64) []byte { return encodeDeltasAlloc(values) })
+}
+
+func BenchmarkDeltaVarintAppend(b *testing.B) { benchmarkDeltaEncode(b, AppendDeltaVarints) }
@@ -0,0 +1,220 @@
+package perf
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// emaAlpha weights the newest sample; 2/(N+1) approximates an N-sample
+// moving average.
+const emaAlpha = 2.0 / (20 + 1)
+
+// --- One heap object per series, found by name ---
+
+// EMA is the state of one exponential moving average. The first sample
+// seeds the average.
+type EMA struct {
+	value  float64
+	primed bool
+}
+
+func (e *EMA) Add(x float64) {
+	if !e.primed {
+		e.value, e.primed = x, true
+		return
+	}
+	e.value += emaAlpha * (x - e.value)
+}
+
+// MapEMAs keeps each series behind a pointer in a map keyed by series name.
+type MapEMAs struct {
+	series map[string]*EMA
+}
+
+func NewMapEMAs() *MapEMAs { return &MapEMAs{series: make(map[string]*EMA)} }
+
+func (m *MapEMAs) Update(name string, x float64) {
+	e, ok := m.series[name]
+	if !ok {
+		e = &EMA{}
+		m.series[name] = e
+	}
+	e.Add(x)
+}
+
+func (m *MapEMAs) Value(name string) float64 { return m.series[name].value }
+
+// Tick applies one sample to every series.
+func (m *MapEMAs) Tick(names []string, xs []float64) {
+	for i, name := range names {
+		m.Update(name, xs[i])
+	}
+}
+
+// --- Parallel arrays indexed by series ID ---
+
+// SeriesEMAs stores all averages as a struct of arrays. Series are
+// registered once and then addressed by a dense ID, so a tick is a single
+// pass over contiguous memory.
+type SeriesEMAs struct {
+	values []float64
+	primed []bool
+}
+
+// NewSeriesEMAs preallocates state for n series with IDs 0..n-1.
+func NewSeriesEMAs(n int) *SeriesEMAs {
+	return &SeriesEMAs{values: make([]float64, n), primed: make([]bool, n)}
+}
+
+func (s *SeriesEMAs) Value(id int) float64 { return s.values[id] }
+
+// Tick applies xs[id] to series id for every series.
+func (s *SeriesEMAs) Tick(xs []float64) {
+	values, primed := s.values, s.primed[:len(s.values)]
+	xs = xs[:len(values)]
+	for id, x := range xs {
+		if !primed[id] {
+			values[id], primed[id] = x, true
+			continue
+		}
+		values[id] += emaAlpha * (x - values[id])
+	}
+}
+
+// referenceEMA computes the average of one series from scratch using the
+// textbook alpha*x + (1-alpha)*prev form.
+func referenceEMA(samples []float64) float64 {
+	v := samples[0]
+	for _, x := range samples[1:] {
+		v = emaAlpha*x + (1-emaAlpha)*v
+	}
+	return v
+}
+
+func emaSeriesNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("host-%04d.cpu.user", i)
+	}
+	return names
+}
+
+// emaTicks generates ticks of samples, one per series, as a random walk.
+func emaTicks(series, ticks int, seed uint64) [][]float64 {
+	rng := rand.New(rand.NewPCG(seed, seed+1))
+	out := make([][]float64, ticks)
+	for t := range out {
+		out[t] = make([]float64, series)
+		for s := range out[t] {
+			prev := 50.0
+			if t > 0 {
+				prev = out[t-1][s]
+			}
+			out[t][s] = prev + rng.NormFloat64()
+		}
+	}
+	return out
+}
+
+func TestEMAKnownValues(t *testing.T) {
+	var e EMA
+	for _, x := range []float64{10, 10, 10} {
+		e.Add(x)
+	}
+	if e.value != 10 {
+		t.Fatalf("EMA of a constant = %v, want 10", e.value)
+	}
+	e.Add(31)
+	if want := 10 + emaAlpha*21; e.value != want {
+		t.Fatalf("after a step to 31, EMA = %v, want %v", e.value, want)
+	}
+}
+
+func TestEMALayoutsMatchReference(t *testing.T) {
+	const series, ticks = 500, 300
+	names := emaSeriesNames(series)
+	data := emaTicks(series, ticks, 1)
+	m, s := NewMapEMAs(), NewSeriesEMAs(series)
+	history := make([][]float64, series)
+	for tick, xs := range data {
+		m.Tick(names, xs)
+		s.Tick(xs)
+		for id := range history {
+			history[id] = append(history[id], xs[id])
+		}
+		if tick%50 != 0 && tick != ticks-1 {
+			continue
+		}
+		for id, name := range names {
+			want := referenceEMA(history[id])
+			if got := m.Value(name); math.Abs(got-want) > 1e-9 {
+				t.Fatalf("tick %d, %s: map EMA %v, reference %v", tick, name, got, want)
+			}
+			if got := s.Value(id); math.Abs(got-want) > 1e-9 {
+				t.Fatalf("tick %d, %s: SoA EMA %v, reference %v", tick, name, got, want)
+			}
+		}
+	}
+}
+
+// Benchmarks track 10,000 series, roughly one scrape of a mid-sized fleet.
+const emaBenchSeries = 10_000
+
+var (
+	emaBenchNames = emaSeriesNames(emaBenchSeries)
+	emaBenchTicks = emaTicks(emaBenchSeries, 16, 2)
+	emaValueSink  float64
+)
+
+// The Tick benchmarks measure steady state: every series already exists and
+// one operation is one tick over all of them.
+
+func BenchmarkEMAMapTick(b *testing.B) {
+	m := NewMapEMAs()
+	m.Tick(emaBenchNames, emaBenchTicks[0])
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Tick(emaBenchNames, emaBenchTicks[i%len(emaBenchTicks)])
+	}
+	emaValueSink = m.Value(emaBenchNames[0])
+}
+
+func BenchmarkEMASoATick(b *testing.B) {
+	s := NewSeriesEMAs(emaBenchSeries)
+	s.Tick(emaBenchTicks[0])
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Tick(emaBenchTicks[i%len(emaBenchTicks)])
+	}
+	emaValueSink = s.Value(0)
+}
+
+// The Build benchmarks start from empty state and run all 16 ticks, as a
+// backtest or a freshly started aggregator would, so per-series setup is
+// included.
+
+func BenchmarkEMAMapBuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := NewMapEMAs()
+		for _, xs := range emaBenchTicks {
+			m.Tick(emaBenchNames, xs)
+		}
+		emaValueSink = m.Value(emaBenchNames[0])
+	}
+}
+
+func BenchmarkEMASoABuild(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := NewSeriesEMAs(emaBenchSeries)
+		for _, xs := range emaBenchTicks {
+			s.Tick(xs)
+		}
+		emaValueSink = s.Value(0)
+	}
+}
@@ -0,0 +1,272 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type pipelineEvent struct {
+	ID     uint64
+	Kind   string
+	Value  int64
+	Source string
+}
+
+var errEventFieldTooLong = errors.New("event: Kind or Source longer than 255 bytes")
+
+// appendEvent serializes ev as a length-prefixed record with a trailing
+// CRC32 so the writer stage can verify it wasn't corrupted in flight. Kind
+// and Source carry one-byte lengths, so a longer field is rejected with
+// errEventFieldTooLong and dst is returned unchanged. With both fields
+// capped, a record is at most a few hundred bytes and its two-byte length
+// can't overflow.
+func appendEvent(dst []byte, ev *pipelineEvent) ([]byte, error) {
+	if len(ev.Kind) > math.MaxUint8 || len(ev.Source) > math.MaxUint8 {
+		return dst, errEventFieldTooLong
+	}
+	start := len(dst)
+	dst = append(dst, 0, 0) // length placeholder
+	dst = binary.AppendUvarint(dst, ev.ID)
+	dst = binary.AppendVarint(dst, ev.Value)
+	dst = append(dst, byte(len(ev.Kind)))
+	dst = append(dst, ev.Kind...)
+	dst = append(dst, byte(len(ev.Source)))
+	dst = append(dst, ev.Source...)
+	dst = binary.LittleEndian.AppendUint32(dst, crc32.ChecksumIEEE(dst[start+2:]))
+	binary.LittleEndian.PutUint16(dst[start:], uint16(len(dst)-start-2))
+	return dst, nil
+}
+
+var errEventCorrupt = errors.New("event: checksum mismatch")
+
+func verifyEvent(rec []byte) error {
+	body := rec[2 : len(rec)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(rec[len(rec)-4:]) {
+		return errEventCorrupt
+	}
+	return nil
+}
+
+// eventBuffer is a serialized event in flight. Ownership moves with the
+// pointer: the serializer fills it, sends it, and must not touch it again;
+// the writer writes it and then recycles it. err is set instead of b when
+// the event couldn't be serialized.
+type eventBuffer struct {
+	b   []byte
+	err error
+}
+
+var eventBufferPool = sync.Pool{
+	New: func() any { return &eventBuffer{b: make([]byte, 0, 128)} },
+}
+
+func recycleEventBuffer(buf *eventBuffer) {
+	buf.b, buf.err = buf.b[:0], nil
+	eventBufferPool.Put(buf)
+}
+
+// runEventPipeline serializes events in one goroutine and writes them to w
+// in another, connected by a channel. With pooled set, buffers are drawn
+// from eventBufferPool and recycled by the writer after each write. An event
+// that can't be serialized is sent as its error and ends the stream, so the
+// writer returns it after writing every event before it.
+func runEventPipeline(events []pipelineEvent, w io.Writer, pooled bool) error {
+	ch := make(chan *eventBuffer, 64)
+	go func() {
+		defer close(ch)
+		for i := range events {
+			var buf *eventBuffer
+			if pooled {
+				buf = eventBufferPool.Get().(*eventBuffer)
+			} else {
+				buf = &eventBuffer{b: make([]byte, 0, 128)}
+			}
+			var err error
+			buf.b, err = appendEvent(buf.b, &events[i])
+			buf.err = err
+			ch <- buf // ownership passes to the writer
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var err error
+	for buf := range ch {
+		if err == nil {
+			err = buf.err
+		}
+		if err == nil {
+			if err = verifyEvent(buf.b); err == nil {
+				_, err = w.Write(buf.b)
+			}
+		}
+		if pooled {
+			recycleEventBuffer(buf)
+		}
+	}
+	return err
+}
+
+func makePipelineEvents(n int) []pipelineEvent {
+	events := make([]pipelineEvent, n)
+	kinds := []string{"click", "view", "purchase", "signup"}
+	for i := range events {
+		events[i] = pipelineEvent{
+			ID:     uint64(i),
+			Kind:   kinds[i%len(kinds)],
+			Value:  int64(i*31) - 5000,
+			Source: "web-" + strconv.Itoa(i%16),
+		}
+	}
+	return events
+}
+
+// decodeEvents parses the writer's output back into events.
+func decodeEvents(data []byte) ([]pipelineEvent, error) {
+	var out []pipelineEvent
+	for len(data) > 0 {
+		n := int(binary.LittleEndian.Uint16(data)) + 2
+		rec := data[:n]
+		if err := verifyEvent(rec); err != nil {
+			return nil, err
+		}
+		body := rec[2 : n-4]
+		var ev pipelineEvent
+		var k int
+		ev.ID, k = binary.Uvarint(body)
+		body = body[k:]
+		ev.Value, k = binary.Varint(body)
+		body = body[k:]
+		kindLen := int(body[0])
+		ev.Kind, body = string(body[1:1+kindLen]), body[1+kindLen:]
+		ev.Source = string(body[1 : 1+int(body[0])])
+		out = append(out, ev)
+		data = data[n:]
+	}
+	return out, nil
+}
+
+func TestEventPipelineIntegrity(t *testing.T) {
+	events := makePipelineEvents(10_000)
+	for _, pooled := range []bool{false, true} {
+		var out bytes.Buffer
+		if err := runEventPipeline(events, &out, pooled); err != nil {
+			t.Fatalf("pooled=%v: %v", pooled, err)
+		}
+		got, err := decodeEvents(out.Bytes())
+		if err != nil {
+			t.Fatalf("pooled=%v: %v", pooled, err)
+		}
+		if len(got) != len(events) {
+			t.Fatalf("pooled=%v: got %d events, want %d", pooled, len(got), len(events))
+		}
+		for i := range events {
+			if got[i] != events[i] {
+				t.Fatalf("pooled=%v event %d: got %+v, want %+v", pooled, i, got[i], events[i])
+			}
+		}
+	}
+}
+
+func TestAppendEventRejectsLongFields(t *testing.T) {
+	long := string(bytes.Repeat([]byte{'s'}, 256))
+	for _, ev := range []pipelineEvent{{Kind: long}, {Source: long}} {
+		dst, err := appendEvent([]byte("prefix"), &ev)
+		if !errors.Is(err, errEventFieldTooLong) || string(dst) != "prefix" {
+			t.Fatalf("%d-byte Kind, %d-byte Source: got %d bytes, %v; want dst unchanged and errEventFieldTooLong",
+				len(ev.Kind), len(ev.Source), len(dst), err)
+		}
+	}
+
+	// 255 bytes is the longest length that fits and must round-trip.
+	ev := pipelineEvent{ID: 1, Kind: long[:255], Source: long[:255]}
+	rec, err := appendEvent(nil, &ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeEvents(rec)
+	if err != nil || len(got) != 1 || got[0] != ev {
+		t.Fatalf("255-byte fields: got %+v, %v", got, err)
+	}
+
+	// In a pipeline, the long event stops serialization and its error comes
+	// back to the caller after the events before it have been written.
+	events := makePipelineEvents(500)
+	events[300].Source = long
+	for _, pooled := range []bool{false, true} {
+		var out bytes.Buffer
+		if err := runEventPipeline(events, &out, pooled); !errors.Is(err, errEventFieldTooLong) {
+			t.Fatalf("pooled=%v: got %v, want errEventFieldTooLong", pooled, err)
+		}
+		if got, err := decodeEvents(out.Bytes()); err != nil || len(got) != 300 {
+			t.Fatalf("pooled=%v: wrote %d events before the long one, %v; want 300", pooled, len(got), err)
+		}
+	}
+}
+
+// scribblingWriter corrupts every buffer it is given after validating it. If
+// a buffer were recycled while still referenced elsewhere, the corruption
+// would surface as a checksum failure on a later event.
+type scribblingWriter struct {
+	n int
+}
+
+func (s *scribblingWriter) Write(p []byte) (int, error) {
+	if err := verifyEvent(p); err != nil {
+		return 0, err
+	}
+	s.n++
+	for i := range p {
+		p[i] = 0xAA
+	}
+	return len(p), nil
+}
+
+func TestEventPipelineNoUseAfterRecycle(t *testing.T) {
+	events := makePipelineEvents(20_000)
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := &scribblingWriter{}
+			if err := runEventPipeline(events, w, true); err != nil {
+				errs <- err
+				return
+			}
+			if w.n != len(events) {
+				errs <- errors.New("events missing from output")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+var pipelineBenchEvents = makePipelineEvents(1000)
+
+func benchmarkEventPipeline(b *testing.B, pooled bool) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := io.Discard
+		for pb.Next() {
+			_ = runEventPipeline(pipelineBenchEvents, w, pooled)
+		}
+	})
+}
+
+func BenchmarkEventPipelineAlloc(b *testing.B) { benchmarkEventPipeline(b, false) }
+
+func BenchmarkEventPipelinePooled(b *testing.B) { benchmarkEventPipeline(b, true) }
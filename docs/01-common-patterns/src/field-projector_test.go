@@ -0,0 +1,368 @@
+package perf
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// tradeRecord is a wide source row; exports usually want a handful of its
+// columns.
+type tradeRecord struct {
+	ID         int64
+	Account    string
+	Symbol     string
+	Side       string
+	Qty        int64
+	PriceCents int64
+	FeeCents   int64
+	Venue      string
+	Trader     string
+	Time       int64
+	Flags      uint32
+	Note       string
+}
+
+type tradeField uint8
+
+const (
+	tradeID tradeField = iota
+	tradeAccount
+	tradeSymbol
+	tradeSide
+	tradeQty
+	tradePrice
+	tradeFee
+	tradeVenue
+	tradeTrader
+	tradeTime
+	tradeFlags
+	tradeNote
+)
+
+var tradeFieldNames = map[string]tradeField{
+	"id": tradeID, "account": tradeAccount, "symbol": tradeSymbol, "side": tradeSide,
+	"qty": tradeQty, "price_cents": tradePrice, "fee_cents": tradeFee, "venue": tradeVenue,
+	"trader": tradeTrader, "ts": tradeTime, "flags": tradeFlags, "note": tradeNote,
+}
+
+var errUnknownColumn = errors.New("projection: unknown column")
+
+// compileProjection resolves column names once, so projecting a record is
+// a switch on small integers instead of a string lookup per field.
+func compileProjection(columns []string) ([]tradeField, error) {
+	fields := make([]tradeField, len(columns))
+	for i, c := range columns {
+		f, ok := tradeFieldNames[c]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errUnknownColumn, c)
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+// ProjectedValue holds one projected column without boxing it: Str is set
+// for string columns and Int for everything else.
+type ProjectedValue struct {
+	IsStr bool
+	Int   int64
+	Str   string
+}
+
+// Any returns the value as database/sql or an encoder would take it.
+func (v ProjectedValue) Any() any {
+	if v.IsStr {
+		return v.Str
+	}
+	return v.Int
+}
+
+func tradeFieldValue(r *tradeRecord, f tradeField) ProjectedValue {
+	switch f {
+	case tradeID:
+		return ProjectedValue{Int: r.ID}
+	case tradeAccount:
+		return ProjectedValue{IsStr: true, Str: r.Account}
+	case tradeSymbol:
+		return ProjectedValue{IsStr: true, Str: r.Symbol}
+	case tradeSide:
+		return ProjectedValue{IsStr: true, Str: r.Side}
+	case tradeQty:
+		return ProjectedValue{Int: r.Qty}
+	case tradePrice:
+		return ProjectedValue{Int: r.PriceCents}
+	case tradeFee:
+		return ProjectedValue{Int: r.FeeCents}
+	case tradeVenue:
+		return ProjectedValue{IsStr: true, Str: r.Venue}
+	case tradeTrader:
+		return ProjectedValue{IsStr: true, Str: r.Trader}
+	case tradeTime:
+		return ProjectedValue{Int: r.Time}
+	case tradeFlags:
+		return ProjectedValue{Int: int64(r.Flags)}
+	default:
+		return ProjectedValue{IsStr: true, Str: r.Note}
+	}
+}
+
+// --- A new map per record ---
+
+func projectToMap(r *tradeRecord, columns []string, fields []tradeField) map[string]any {
+	m := make(map[string]any, len(fields))
+	for i, f := range fields {
+		m[columns[i]] = tradeFieldValue(r, f).Any()
+	}
+	return m
+}
+
+// --- A reused []any row ---
+
+// projectToRow fills row, which has one slot per field. The slice is
+// reused, but every value is still boxed into an interface.
+func projectToRow(r *tradeRecord, fields []tradeField, row []any) {
+	for i, f := range fields {
+		row[i] = tradeFieldValue(r, f).Any()
+	}
+}
+
+// --- A reused typed row ---
+
+// FieldProjector copies a fixed set of columns out of each record into a
+// row it keeps. The row returned by Project is overwritten by the next call.
+type FieldProjector struct {
+	fields []tradeField
+	row    []ProjectedValue
+}
+
+func NewFieldProjector(columns []string) (*FieldProjector, error) {
+	p := &FieldProjector{}
+	if err := p.Reset(columns); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reset switches to a new column set, keeping the row's storage. Slots
+// beyond the new width are cleared so they don't hold on to old strings.
+func (p *FieldProjector) Reset(columns []string) error {
+	fields, err := compileProjection(columns)
+	if err != nil {
+		return err
+	}
+	p.fields = fields
+	clear(p.row[:cap(p.row)])
+	p.row = p.row[:0]
+	p.row = append(p.row, make([]ProjectedValue, len(fields))...)
+	return nil
+}
+
+// Project fills the row from r. Each slot is assigned whole, so a value
+// from the previous record can't survive in it.
+func (p *FieldProjector) Project(r *tradeRecord) []ProjectedValue {
+	for i, f := range p.fields {
+		p.row[i] = tradeFieldValue(r, f)
+	}
+	return p.row
+}
+
+func TestFieldProjectorMatchesMapAndRow(t *testing.T) {
+	all := make([]string, 0, len(tradeFieldNames))
+	for name := range tradeFieldNames {
+		all = append(all, name)
+	}
+	for _, columns := range [][]string{
+		all, projectorBenchColumns, {"note"}, {"qty", "id", "qty"}, {},
+	} {
+		fields, err := compileProjection(columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := NewFieldProjector(columns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		row := make([]any, len(fields))
+		for i := range projectorRecords[:1000] {
+			r := &projectorRecords[i]
+			m := projectToMap(r, columns, fields)
+			projectToRow(r, fields, row)
+			got := p.Project(r)
+			if len(got) != len(columns) {
+				t.Fatalf("%v: projected %d values", columns, len(got))
+			}
+			for j, c := range columns {
+				if got[j].Any() != m[c] || row[j] != m[c] {
+					t.Fatalf("record %d column %q: projector %v, row %v, map %v", i, c, got[j].Any(), row[j], m[c])
+				}
+			}
+		}
+	}
+
+	p, _ := NewFieldProjector([]string{"id", "symbol", "flags", "note"})
+	r := &tradeRecord{ID: 42, Symbol: "ACME", Flags: 7, Note: "manual"}
+	want := []ProjectedValue{{Int: 42}, {IsStr: true, Str: "ACME"}, {Int: 7}, {IsStr: true, Str: "manual"}}
+	for i, v := range p.Project(r) {
+		if v != want[i] {
+			t.Fatalf("column %d = %+v, want %+v", i, v, want[i])
+		}
+	}
+}
+
+func TestFieldProjectorNoStaleFields(t *testing.T) {
+	p, err := NewFieldProjector([]string{"account", "note", "qty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Project(&tradeRecord{Account: "acct-1", Note: "secret", Qty: 5})
+	row := p.Project(&tradeRecord{Account: "acct-2"})
+	if row[0].Str != "acct-2" || row[1].Str != "" || row[2].Int != 0 {
+		t.Fatalf("second record kept values from the first: %+v", row)
+	}
+
+	// A narrower column set must not keep the wider row's strings reachable.
+	if err := p.Reset([]string{"qty"}); err != nil {
+		t.Fatal(err)
+	}
+	p.Project(&tradeRecord{Qty: 9})
+	if full := p.row[:cap(p.row)]; full[0] != (ProjectedValue{Int: 9}) || full[1] != (ProjectedValue{}) || full[2] != (ProjectedValue{}) {
+		t.Fatalf("after Reset: %+v", full)
+	}
+}
+
+func TestFieldProjectorUnknownColumn(t *testing.T) {
+	if _, err := NewFieldProjector([]string{"id", "price"}); !errors.Is(err, errUnknownColumn) {
+		t.Fatalf("err = %v, want errUnknownColumn", err)
+	}
+	p, _ := NewFieldProjector([]string{"id"})
+	if err := p.Reset([]string{"nope"}); !errors.Is(err, errUnknownColumn) {
+		t.Fatalf("Reset err = %v, want errUnknownColumn", err)
+	}
+	if len(p.Project(&projectorRecords[0])) != 1 {
+		t.Fatal("a failed Reset changed the column set")
+	}
+}
+
+func TestFieldProjectorDoesNotAllocate(t *testing.T) {
+	p, _ := NewFieldProjector(projectorBenchColumns)
+	i := 0
+	allocs := testing.AllocsPerRun(1000, func() {
+		projectorSink += len(p.Project(&projectorRecords[i%len(projectorRecords)]))
+		i++
+	})
+	if allocs != 0 {
+		t.Fatalf("Project allocated %v times", allocs)
+	}
+}
+
+// projectorRecords are 200,000 trades. Strings come from small pools, as
+// they would after a driver interned them, so generating the records doesn't
+// dominate memory.
+var projectorRecords = func() []tradeRecord {
+	rng := rand.New(rand.NewPCG(37, 37))
+	pool := func(prefix string, n int) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("%s-%d", prefix, i)
+		}
+		return out
+	}
+	accounts, symbols, venues, traders := pool("acct", 500), pool("SYM", 200), pool("venue", 8), pool("trader", 50)
+	out := make([]tradeRecord, 200_000)
+	for i := range out {
+		out[i] = tradeRecord{
+			ID:         1_000_000 + int64(i),
+			Account:    accounts[rng.IntN(len(accounts))],
+			Symbol:     symbols[rng.IntN(len(symbols))],
+			Side:       [2]string{"buy", "sell"}[rng.IntN(2)],
+			Qty:        1 + rng.Int64N(1000),
+			PriceCents: 100 + rng.Int64N(100_000),
+			FeeCents:   rng.Int64N(500),
+			Venue:      venues[rng.IntN(len(venues))],
+			Trader:     traders[rng.IntN(len(traders))],
+			Time:       1_760_000_000_000 + int64(i)*3,
+			Flags:      rng.Uint32() & 0xff,
+		}
+		if rng.IntN(20) == 0 {
+			out[i].Note = "manual adjustment"
+		}
+	}
+	return out
+}()
+
+var (
+	projectorBenchColumns = []string{"id", "symbol", "qty", "price_cents", "trader"}
+	projectorSink         int
+)
+
+// Each operation projects one million records: five passes over the
+// 200,000 trades, keeping five of their twelve columns. The consumer adds
+// up the integers and string lengths so every value is read.
+const projectorPasses = 5
+
+func BenchmarkProjectNewMap(b *testing.B) {
+	fields, _ := compileProjection(projectorBenchColumns)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sum := 0
+		for range projectorPasses {
+			for j := range projectorRecords {
+				m := projectToMap(&projectorRecords[j], projectorBenchColumns, fields)
+				for _, c := range projectorBenchColumns {
+					switch v := m[c].(type) {
+					case int64:
+						sum += int(v)
+					case string:
+						sum += len(v)
+					}
+				}
+			}
+		}
+		projectorSink = sum
+	}
+}
+
+func BenchmarkProjectReusedAnyRow(b *testing.B) {
+	fields, _ := compileProjection(projectorBenchColumns)
+	row := make([]any, len(fields))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sum := 0
+		for range projectorPasses {
+			for j := range projectorRecords {
+				projectToRow(&projectorRecords[j], fields, row)
+				for _, v := range row {
+					switch v := v.(type) {
+					case int64:
+						sum += int(v)
+					case string:
+						sum += len(v)
+					}
+				}
+			}
+		}
+		projectorSink = sum
+	}
+}
+
+func BenchmarkProjectReusedTypedRow(b *testing.B) {
+	p, _ := NewFieldProjector(projectorBenchColumns)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sum := 0
+		for range projectorPasses {
+			for j := range projectorRecords {
+				for _, v := range p.Project(&projectorRecords[j]) {
+					if v.IsStr {
+						sum += len(v.Str)
+					} else {
+						sum += int(v.Int)
+					}
+				}
+			}
+		}
+		projectorSink = sum
+	}
+}
@@ -0,0 +1,238 @@
+package perf
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// histBucket maps v to one of n equal-width buckets starting at min.
+// Values below the range land in the first bucket, values at or above it
+// in the last. The comparisons happen in floating point so that infinities
+// never reach the int conversion.
+func histBucket(v, min, invWidth float64, n int) int {
+	f := (v - min) * invWidth
+	switch {
+	case f >= float64(n):
+		return n - 1
+	case f >= 1:
+		return int(f)
+	default:
+		return 0
+	}
+}
+
+// checkHistogramArgs panics unless there is at least one bucket and
+// [min, max) is a non-empty finite range, so that every bucket has a
+// positive, finite width. name prefixes the message with the constructor.
+func checkHistogramArgs(name string, min, max float64, buckets int) {
+	if buckets < 1 {
+		panic(name + ": buckets must be at least 1")
+	}
+	if !(max > min) || math.IsInf(max-min, 0) {
+		panic(name + ": max must be finite and greater than min")
+	}
+}
+
+// --- Map keyed by bucket lower bound ---
+
+type MapHistogram struct {
+	min, width float64
+	n          int
+	counts     map[float64]uint64
+}
+
+// NewMapHistogram panics on invalid bounds; see checkHistogramArgs.
+func NewMapHistogram(min, max float64, buckets int) *MapHistogram {
+	checkHistogramArgs("NewMapHistogram", min, max, buckets)
+	return &MapHistogram{min: min, width: (max - min) / float64(buckets), n: buckets, counts: make(map[float64]uint64)}
+}
+
+// Observe counts v. NaN is ignored.
+func (h *MapHistogram) Observe(v float64) {
+	if v != v {
+		return
+	}
+	i := histBucket(v, h.min, 1/h.width, h.n)
+	h.counts[h.min+float64(i)*h.width]++
+}
+
+// Counts returns the bucket counts in order.
+func (h *MapHistogram) Counts() []uint64 {
+	out := make([]uint64, h.n)
+	for i := range out {
+		out[i] = h.counts[h.min+float64(i)*h.width]
+	}
+	return out
+}
+
+// --- Preallocated bucket array ---
+
+// FixedHistogram counts observations in a []uint64 allocated once, so
+// Observe is one multiply, one compare chain, and one increment.
+type FixedHistogram struct {
+	min, invWidth float64
+	counts        []uint64
+}
+
+// NewFixedHistogram panics on invalid bounds, like NewMapHistogram.
+func NewFixedHistogram(min, max float64, buckets int) *FixedHistogram {
+	checkHistogramArgs("NewFixedHistogram", min, max, buckets)
+	return &FixedHistogram{min: min, invWidth: float64(buckets) / (max - min), counts: make([]uint64, buckets)}
+}
+
+// Observe counts v. NaN is ignored. It never allocates.
+func (h *FixedHistogram) Observe(v float64) {
+	if v != v {
+		return
+	}
+	h.counts[histBucket(v, h.min, h.invWidth, len(h.counts))]++
+}
+
+// Counts returns the bucket counts; the slice is owned by the histogram.
+func (h *FixedHistogram) Counts() []uint64 { return h.counts }
+
+// Reset zeroes every bucket, keeping the array.
+func (h *FixedHistogram) Reset() { clear(h.counts) }
+
+func TestFixedHistogramCounts(t *testing.T) {
+	h := NewFixedHistogram(0, 100, 10)
+	for _, v := range []float64{0, 5, 9.999, 10, 55, 99.9} {
+		h.Observe(v)
+	}
+	want := []uint64{3, 1, 0, 0, 0, 1, 0, 0, 0, 1}
+	if got := h.Counts(); !slices.Equal(got, want) {
+		t.Fatalf("counts = %v, want %v", got, want)
+	}
+}
+
+func TestHistogramClampsOutOfRange(t *testing.T) {
+	fixed, mapped := NewFixedHistogram(0, 100, 10), NewMapHistogram(0, 100, 10)
+	for _, v := range []float64{-1, -1e300, math.Inf(-1), 100, 150, 1e300, math.Inf(1), math.NaN()} {
+		fixed.Observe(v)
+		mapped.Observe(v)
+	}
+	want := []uint64{3, 0, 0, 0, 0, 0, 0, 0, 0, 4} // NaN is dropped
+	if got := fixed.Counts(); !slices.Equal(got, want) {
+		t.Errorf("fixed histogram counts = %v, want %v", got, want)
+	}
+	if got := mapped.Counts(); !slices.Equal(got, want) {
+		t.Errorf("map histogram counts = %v, want %v", got, want)
+	}
+}
+
+func TestHistogramRejectsBadBounds(t *testing.T) {
+	cases := []struct {
+		min, max float64
+		buckets  int
+	}{
+		{0, 100, 0}, {0, 100, -1}, {100, 100, 10}, {100, 0, 10},
+		{math.NaN(), 100, 10}, {0, math.NaN(), 10}, {0, math.Inf(1), 10}, {-math.MaxFloat64, math.MaxFloat64, 10},
+	}
+	for name, build := range map[string]func(min, max float64, buckets int){
+		"map":   func(min, max float64, n int) { NewMapHistogram(min, max, n) },
+		"fixed": func(min, max float64, n int) { NewFixedHistogram(min, max, n) },
+	} {
+		for _, c := range cases {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s: min %v, max %v, %d buckets did not panic", name, c.min, c.max, c.buckets)
+					}
+				}()
+				build(c.min, c.max, c.buckets)
+			}()
+		}
+	}
+	// One bucket over a valid range holds everything.
+	h := NewFixedHistogram(0, 1, 1)
+	h.Observe(-5)
+	h.Observe(0.5)
+	h.Observe(5)
+	if got := h.Counts(); !slices.Equal(got, []uint64{3}) {
+		t.Fatalf("single bucket counts = %v, want [3]", got)
+	}
+}
+
+func TestHistogramsAgree(t *testing.T) {
+	values := histLatencies(200_000, 3)
+	fixed, mapped := NewFixedHistogram(0, histMaxMs, histBuckets), NewMapHistogram(0, histMaxMs, histBuckets)
+	var total uint64
+	for _, v := range values {
+		fixed.Observe(v)
+		mapped.Observe(v)
+	}
+	if got, want := fixed.Counts(), mapped.Counts(); !slices.Equal(got, want) {
+		t.Fatalf("fixed %v\nmap   %v", got, want)
+	}
+	for _, c := range fixed.Counts() {
+		total += c
+	}
+	if total != uint64(len(values)) {
+		t.Fatalf("buckets hold %d observations, want %d", total, len(values))
+	}
+	fixed.Reset()
+	if slices.ContainsFunc(fixed.Counts(), func(c uint64) bool { return c != 0 }) {
+		t.Fatal("Reset left non-zero buckets")
+	}
+}
+
+func TestFixedHistogramObserveDoesNotAllocate(t *testing.T) {
+	h := NewFixedHistogram(0, histMaxMs, histBuckets)
+	v := 0.0
+	allocs := testing.AllocsPerRun(10000, func() {
+		h.Observe(v)
+		v += 0.37
+	})
+	if allocs != 0 {
+		t.Fatalf("Observe allocated %v times", allocs)
+	}
+}
+
+// Request latencies in milliseconds, log-normal around 20 ms with a long
+// tail, bucketed into 200 buckets of 5 ms up to one second.
+const (
+	histMaxMs         = 1000
+	histBuckets       = 200
+	histObservationsN = 10_000_000
+)
+
+func histLatencies(n int, seed uint64) []float64 {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.Exp(3 + rng.NormFloat64())
+	}
+	return out
+}
+
+var (
+	histBenchValues = histLatencies(1<<20, 1)
+	histCountSink   uint64
+)
+
+// Each operation observes 10M values, cycling through 2^20 precomputed
+// latencies.
+
+func BenchmarkHistogramMap(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h := NewMapHistogram(0, histMaxMs, histBuckets)
+		for j := 0; j < histObservationsN; j++ {
+			h.Observe(histBenchValues[j&(len(histBenchValues)-1)])
+		}
+		histCountSink = h.Counts()[0]
+	}
+}
+
+func BenchmarkHistogramFixed(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h := NewFixedHistogram(0, histMaxMs, histBuckets)
+		for j := 0; j < histObservationsN; j++ {
+			h.Observe(histBenchValues[j&(len(histBenchValues)-1)])
+		}
+		histCountSink = h.Counts()[0]
+	}
+}
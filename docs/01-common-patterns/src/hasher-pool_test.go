@@ -0,0 +1,174 @@
+package perf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sync"
+	"testing"
+)
+
+// signedMessage is hashed in parts, the way request signers hash a method,
+// a set of headers, and a body without concatenating them first.
+type signedMessage struct {
+	header []byte
+	body   []byte
+	nonce  uint64
+}
+
+// writeMessage feeds m to h, using scratch to encode the nonce. Any slice
+// passed to an interface method escapes, so scratch must outlive the call.
+func writeMessage(h hash.Hash, m *signedMessage, scratch *[8]byte) {
+	binary.BigEndian.PutUint64(scratch[:], m.nonce)
+	h.Write(m.header)
+	h.Write(scratch[:])
+	h.Write(m.body)
+}
+
+// digestFresh creates a new hasher and a new digest slice for every message.
+func digestFresh(m *signedMessage) []byte {
+	var scratch [8]byte
+	h := sha256.New()
+	writeMessage(h, m, &scratch)
+	return h.Sum(nil)
+}
+
+// pooledHasher keeps a hasher together with the scratch space its callers
+// need, so neither is allocated per message.
+type pooledHasher struct {
+	h       hash.Hash
+	scratch [8]byte
+}
+
+var sha256Pool = sync.Pool{
+	New: func() any { return &pooledHasher{h: sha256.New()} },
+}
+
+// digestPooled reuses a pooled hasher and writes the digest into out.
+func digestPooled(m *signedMessage, out *[sha256.Size]byte) {
+	ph := sha256Pool.Get().(*pooledHasher)
+	ph.h.Reset()
+	writeMessage(ph.h, m, &ph.scratch)
+	ph.h.Sum(out[:0])
+	sha256Pool.Put(ph)
+}
+
+func makeSignedMessages(n, bodySize int) []signedMessage {
+	msgs := make([]signedMessage, n)
+	for i := range msgs {
+		body := make([]byte, bodySize)
+		for j := range body {
+			body[j] = byte(i*7 + j)
+		}
+		msgs[i] = signedMessage{
+			header: []byte("POST /v1/orders host=api.example.com content-type=application/json"),
+			body:   body,
+			nonce:  uint64(i) * 2654435761,
+		}
+	}
+	return msgs
+}
+
+// concatDigest is the reference: hash the concatenated message in one shot.
+func concatDigest(m *signedMessage) [sha256.Size]byte {
+	var nonce [8]byte
+	binary.BigEndian.PutUint64(nonce[:], m.nonce)
+	return sha256.Sum256(bytes.Join([][]byte{m.header, nonce[:], m.body}, nil))
+}
+
+func TestPooledDigestsMatchFresh(t *testing.T) {
+	msgs := makeSignedMessages(200, 300)
+	for i := range msgs {
+		want := concatDigest(&msgs[i])
+		if got := digestFresh(&msgs[i]); !bytes.Equal(got, want[:]) {
+			t.Fatalf("message %d: fresh digest %x, want %x", i, got, want)
+		}
+		var got [sha256.Size]byte
+		digestPooled(&msgs[i], &got)
+		if got != want {
+			t.Fatalf("message %d: pooled digest %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestHasherResetClearsState(t *testing.T) {
+	msgs := makeSignedMessages(2, 1000)
+	var scratch [8]byte
+	h := sha256.New()
+	writeMessage(h, &msgs[0], &scratch)
+	h.Write([]byte("trailing")) // leave a partial block buffered
+	h.Reset()
+	writeMessage(h, &msgs[1], &scratch)
+	want := concatDigest(&msgs[1])
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Fatalf("digest after Reset %x, want %x", got, want)
+	}
+}
+
+func TestPooledDigestsConcurrent(t *testing.T) {
+	msgs := makeSignedMessages(500, 256)
+	want := make([][sha256.Size]byte, len(msgs))
+	for i := range msgs {
+		want[i] = concatDigest(&msgs[i])
+	}
+	var wg sync.WaitGroup
+	errs := make(chan int, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := range msgs {
+				j := (i + g*61) % len(msgs)
+				var got [sha256.Size]byte
+				digestPooled(&msgs[j], &got)
+				if got != want[j] {
+					errs <- j
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for j := range errs {
+		t.Fatalf("message %d: concurrent pooled digest mismatch", j)
+	}
+}
+
+func TestPooledDigestAllocations(t *testing.T) {
+	msgs := makeSignedMessages(1, 256)
+	var out [sha256.Size]byte
+	digestPooled(&msgs[0], &out)
+	allocs := testing.AllocsPerRun(1000, func() {
+		digestPooled(&msgs[0], &out)
+	})
+	if allocs != 0 {
+		t.Fatalf("pooled digest allocated %v times", allocs)
+	}
+}
+
+var hashBenchMessages = makeSignedMessages(1024, 256)
+
+func BenchmarkSHA256NewPerMessage(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_ = digestFresh(&hashBenchMessages[i%len(hashBenchMessages)])
+			i++
+		}
+	})
+}
+
+func BenchmarkSHA256Pooled(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		var out [sha256.Size]byte
+		i := 0
+		for pb.Next() {
+			digestPooled(&hashBenchMessages[i%len(hashBenchMessages)], &out)
+			i++
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package perf
+
+import (
+	"math"
+	"math/bits"
+	"runtime"
+	"testing"
+)
+
+// hllPrecision is the number of hash bits used to pick a register. 2^14
+// one-byte registers take 16 KB and give a standard error of
+// 1.04/sqrt(2^14), about 0.81%, at any cardinality.
+const (
+	hllPrecision = 14
+	hllRegisters = 1 << hllPrecision
+)
+
+// hllMix is the splitmix64 finalizer. Sequential or clustered IDs need a
+// well-mixed hash, because the estimator relies on every bit of it looking
+// random.
+func hllMix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// HyperLogLog estimates the number of distinct values it has seen in a
+// fixed 16 KB array, no matter how long the stream is or how many distinct
+// values it holds.
+type HyperLogLog struct {
+	registers [hllRegisters]uint8
+}
+
+func NewHyperLogLog() *HyperLogLog { return &HyperLogLog{} }
+
+// Add records v. The top bits of its hash choose a register, which keeps
+// the longest run of leading zeros seen in the remaining bits.
+func (h *HyperLogLog) Add(v uint64) {
+	x := hllMix(v)
+	idx := x >> (64 - hllPrecision)
+	// The guard bit caps the count if every remaining bit is zero.
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Estimate returns the estimated number of distinct values added.
+func (h *HyperLogLog) Estimate() uint64 {
+	const m = float64(hllRegisters)
+	alpha := 0.7213 / (1 + 1.079/m)
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	est := alpha * m * m / sum
+	// For small cardinalities, many registers are still empty, and counting
+	// them (linear counting) is more accurate than the harmonic mean.
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return uint64(est + 0.5)
+}
+
+// Reset clears every register.
+func (h *HyperLogLog) Reset() { clear(h.registers[:]) }
+
+// exactDistinct is the baseline: one map entry per distinct value.
+func exactDistinct(stream func(yield func(uint64))) uint64 {
+	seen := make(map[uint64]struct{})
+	stream(func(v uint64) { seen[v] = struct{}{} })
+	return uint64(len(seen))
+}
+
+// hllStream yields n values with exactly distinct different values among
+// them. Multiplying by an odd constant is a bijection on uint64, so the
+// IDs are scattered but i%distinct fixes the cardinality.
+func hllStream(n, distinct int) func(yield func(uint64)) {
+	return func(yield func(uint64)) {
+		for i := 0; i < n; i++ {
+			yield(uint64(i%distinct) * 0x9e3779b97f4a7c15)
+		}
+	}
+}
+
+func TestHyperLogLogWithinErrorBound(t *testing.T) {
+	// Three standard errors; the test is deterministic, so this bound is
+	// about whether the estimator is implemented correctly, not luck.
+	bound := 3 * 1.04 / math.Sqrt(hllRegisters)
+	for _, distinct := range []int{1_000, 10_000, 50_000, 100_000, 1_000_000, 3_000_000} {
+		h := NewHyperLogLog()
+		hllStream(2*distinct, distinct)(h.Add)
+		est := h.Estimate()
+		if rel := math.Abs(float64(est)-float64(distinct)) / float64(distinct); rel > bound {
+			t.Errorf("distinct %d: estimate %d, error %.2f%% exceeds %.2f%%", distinct, est, 100*rel, 100*bound)
+		}
+	}
+}
+
+func TestHyperLogLogSmallCardinalities(t *testing.T) {
+	h := NewHyperLogLog()
+	if got := h.Estimate(); got != 0 {
+		t.Fatalf("empty estimator: %d, want 0", got)
+	}
+	for _, distinct := range []int{1, 10, 100, 500} {
+		h.Reset()
+		hllStream(10*distinct, distinct)(h.Add)
+		// Linear counting is nearly exact while most registers are empty: its
+		// standard deviation is sqrt(m(e^t - t - 1)) for t = n/m.
+		tl := float64(distinct) / hllRegisters
+		bound := 3*math.Sqrt(hllRegisters*(math.Exp(tl)-tl-1)) + 1
+		if got := h.Estimate(); math.Abs(float64(got)-float64(distinct)) > bound {
+			t.Errorf("distinct %d: estimate %d, want within %.1f", distinct, got, bound)
+		}
+	}
+}
+
+func TestHyperLogLogIgnoresDuplicates(t *testing.T) {
+	once, many := NewHyperLogLog(), NewHyperLogLog()
+	hllStream(20_000, 20_000)(once.Add)
+	hllStream(1_000_000, 20_000)(many.Add)
+	if once.registers != many.registers {
+		t.Fatal("adding each value 50 times changed the registers")
+	}
+}
+
+func TestExactDistinct(t *testing.T) {
+	if got := exactDistinct(hllStream(10_000, 1234)); got != 1234 {
+		t.Fatalf("exactDistinct = %d, want 1234", got)
+	}
+}
+
+func TestHyperLogLogAddDoesNotAllocate(t *testing.T) {
+	h := NewHyperLogLog()
+	v := uint64(0)
+	allocs := testing.AllocsPerRun(10_000, func() {
+		h.Add(v)
+		v++
+	})
+	if allocs != 0 {
+		t.Fatalf("Add allocated %v times", allocs)
+	}
+}
+
+// Each operation counts a stream of 10M IDs with 5M distinct values.
+// heap-B is the growth in live heap at the end of an operation, measured
+// with runtime.ReadMemStats.
+const (
+	hllStreamLen   = 10_000_000
+	hllDistinctLen = 5_000_000
+)
+
+var (
+	hllCountSink  uint64
+	hllSketchSink *HyperLogLog
+)
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func BenchmarkDistinctExactMap(b *testing.B) {
+	var peak uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		base := heapAlloc()
+		b.StartTimer()
+
+		seen := make(map[uint64]struct{})
+		hllStream(hllStreamLen, hllDistinctLen)(func(v uint64) { seen[v] = struct{}{} })
+		hllCountSink = uint64(len(seen))
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		runtime.KeepAlive(seen)
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+}
+
+func BenchmarkDistinctHyperLogLog(b *testing.B) {
+	var peak uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		base := heapAlloc()
+		b.StartTimer()
+
+		// Storing the sketch keeps it on the heap, where a long-lived
+		// estimator would be, instead of the benchmark's stack.
+		sketch := NewHyperLogLog()
+		hllSketchSink = sketch
+		hllStream(hllStreamLen, hllDistinctLen)(sketch.Add)
+		hllCountSink = sketch.Estimate()
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+	b.ReportMetric(100*math.Abs(float64(hllCountSink)-hllDistinctLen)/hllDistinctLen, "err-%")
+}
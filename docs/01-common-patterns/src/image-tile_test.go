@@ -0,0 +1,262 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// defaultTileSize is the tile edge length used by the tests and benchmarks.
+// Real pipelines pick it at runtime to match the codec or cache size.
+const defaultTileSize = 64
+
+type grayImage struct {
+	w, h int
+	pix  []uint8
+}
+
+func newGrayImage(w, h int) *grayImage {
+	return &grayImage{w: w, h: h, pix: make([]uint8, w*h)}
+}
+
+// at returns the pixel at (x, y), clamping coordinates to the image edge.
+func (m *grayImage) at(x, y int) uint8 {
+	x = min(max(x, 0), m.w-1)
+	y = min(max(y, 0), m.h-1)
+	return m.pix[y*m.w+x]
+}
+
+func (m *grayImage) tiles(size int) (int, int) {
+	return (m.w + size - 1) / size, (m.h + size - 1) / size
+}
+
+// tileBuffer is the scratch space for one tile: the input tile with a
+// one-pixel border on each side, and the filtered output.
+type tileBuffer struct {
+	size int
+	in   []uint8 // (size+2) x (size+2)
+	out  []uint8 // size x size
+}
+
+func newTileBuffer(size int) *tileBuffer {
+	return &tileBuffer{
+		size: size,
+		in:   make([]uint8, (size+2)*(size+2)),
+		out:  make([]uint8, size*size),
+	}
+}
+
+// tilePool recycles tile buffers of one fixed size.
+type tilePool struct {
+	size int
+	pool sync.Pool
+}
+
+func newTilePool(size int) *tilePool {
+	p := &tilePool{size: size}
+	p.pool.New = func() any { return newTileBuffer(size) }
+	return p
+}
+
+func (p *tilePool) Get() *tileBuffer { return p.pool.Get().(*tileBuffer) }
+
+func (p *tilePool) Put(buf *tileBuffer) {
+	if buf.size == p.size {
+		p.pool.Put(buf)
+	}
+}
+
+// tileFunc processes tile (tx, ty) of src into dst.
+type tileFunc func(src, dst *grayImage, tx, ty int)
+
+// blurTile applies a 3x3 box blur to tile (tx, ty) of src and writes the
+// result into the same tile of dst. Edge tiles may be smaller than the
+// buffer; only the w x h region of buf is read after it is written, so a
+// recycled buffer's stale contents never leak into the output.
+func blurTile(src, dst *grayImage, tx, ty int, buf *tileBuffer) {
+	size, stride := buf.size, buf.size+2
+	x0, y0 := tx*size, ty*size
+	w, h := min(size, src.w-x0), min(size, src.h-y0)
+
+	for y := -1; y <= h; y++ {
+		row := buf.in[(y+1)*stride:]
+		for x := -1; x <= w; x++ {
+			row[x+1] = src.at(x0+x, y0+y)
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum int
+			for dy := 0; dy < 3; dy++ {
+				r := buf.in[(y+dy)*stride+x:]
+				sum += int(r[0]) + int(r[1]) + int(r[2])
+			}
+			buf.out[y*size+x] = uint8(sum / 9)
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		copy(dst.pix[(y0+y)*dst.w+x0:][:w], buf.out[y*size:][:w])
+	}
+}
+
+func freshTiles(size int) tileFunc {
+	return func(src, dst *grayImage, tx, ty int) {
+		blurTile(src, dst, tx, ty, newTileBuffer(size))
+	}
+}
+
+func pooledTiles(p *tilePool) tileFunc {
+	return func(src, dst *grayImage, tx, ty int) {
+		buf := p.Get()
+		blurTile(src, dst, tx, ty, buf)
+		p.Put(buf)
+	}
+}
+
+// blurImageParallel fans the tiles of src out to workers goroutines. Each
+// tile is written by exactly one worker, so writes to dst never overlap.
+func blurImageParallel(src, dst *grayImage, size, workers int, tile tileFunc) {
+	nx, ny := src.tiles(size)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= nx*ny {
+					return
+				}
+				tile(src, dst, i%nx, i/nx)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func randomGrayImage(w, h int, seed uint64) *grayImage {
+	rng := rand.New(rand.NewPCG(seed, seed^0x5eed))
+	m := newGrayImage(w, h)
+	for i := range m.pix {
+		m.pix[i] = uint8(rng.UintN(256))
+	}
+	return m
+}
+
+// naiveBlur is the reference: a direct 3x3 box blur over the whole image.
+func naiveBlur(src *grayImage) *grayImage {
+	dst := newGrayImage(src.w, src.h)
+	for y := 0; y < src.h; y++ {
+		for x := 0; x < src.w; x++ {
+			var sum int
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					sum += int(src.at(x+dx, y+dy))
+				}
+			}
+			dst.pix[y*src.w+x] = uint8(sum / 9)
+		}
+	}
+	return dst
+}
+
+func TestTileBlurMatchesReference(t *testing.T) {
+	// Dimensions that aren't multiples of the tile size exercise partial
+	// edge tiles.
+	src := randomGrayImage(300, 190, 1)
+	want := naiveBlur(src)
+
+	for name, tile := range map[string]tileFunc{
+		"fresh":  freshTiles(defaultTileSize),
+		"pooled": pooledTiles(newTilePool(defaultTileSize)),
+	} {
+		dst := newGrayImage(src.w, src.h)
+		blurImageParallel(src, dst, defaultTileSize, 1, tile)
+		for i := range want.pix {
+			if dst.pix[i] != want.pix[i] {
+				t.Fatalf("%s: pixel (%d, %d) = %d, want %d", name, i%src.w, i/src.w, dst.pix[i], want.pix[i])
+			}
+		}
+	}
+}
+
+func TestTileBlurParallelPooledNoContamination(t *testing.T) {
+	// Seed the pool with buffers full of garbage so any read of stale data
+	// shows up as a wrong pixel.
+	pool := newTilePool(defaultTileSize)
+	for i := 0; i < 16; i++ {
+		buf := newTileBuffer(defaultTileSize)
+		for j := range buf.in {
+			buf.in[j] = 0xFF
+		}
+		for j := range buf.out {
+			buf.out[j] = 0xFF
+		}
+		pool.Put(buf)
+	}
+
+	src := randomGrayImage(517, 389, 2)
+	want := naiveBlur(src)
+	for round := 0; round < 4; round++ {
+		dst := newGrayImage(src.w, src.h)
+		blurImageParallel(src, dst, defaultTileSize, 8, pooledTiles(pool))
+		for i := range want.pix {
+			if dst.pix[i] != want.pix[i] {
+				t.Fatalf("round %d: pixel (%d, %d) = %d, want %d", round, i%src.w, i/src.w, dst.pix[i], want.pix[i])
+			}
+		}
+	}
+}
+
+func TestTilePoolRejectsOtherSizes(t *testing.T) {
+	pool := newTilePool(defaultTileSize)
+	pool.Put(newTileBuffer(defaultTileSize / 2))
+	for i := 0; i < 4; i++ {
+		if buf := pool.Get(); buf.size != defaultTileSize {
+			t.Fatalf("pool returned a %d-pixel tile buffer, want %d", buf.size, defaultTileSize)
+		}
+	}
+}
+
+func TestTileBlurPooledAllocations(t *testing.T) {
+	src := randomGrayImage(256, 256, 3)
+	dst := newGrayImage(src.w, src.h)
+	tile := pooledTiles(newTilePool(defaultTileSize))
+	tile(src, dst, 0, 0)
+	allocs := testing.AllocsPerRun(100, func() {
+		tile(src, dst, 1, 1)
+	})
+	if allocs != 0 {
+		t.Fatalf("pooled tile allocated %v times per tile", allocs)
+	}
+}
+
+var tileBenchImage = randomGrayImage(1024, 1024, 4)
+
+// benchmarkTiles runs one tile per operation across parallel workers. Each
+// worker writes into its own output image and walks the tiles in order.
+func benchmarkTiles(b *testing.B, tile tileFunc) {
+	src := tileBenchImage
+	nx, ny := src.tiles(defaultTileSize)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		dst := newGrayImage(src.w, src.h)
+		i := 0
+		for pb.Next() {
+			tile(src, dst, i%nx, i/nx)
+			i = (i + 1) % (nx * ny)
+		}
+	})
+}
+
+func BenchmarkTileBlurFresh(b *testing.B) {
+	benchmarkTiles(b, freshTiles(defaultTileSize))
+}
+
+func BenchmarkTileBlurPooled(b *testing.B) {
+	benchmarkTiles(b, pooledTiles(newTilePool(defaultTileSize)))
+}
@@ -0,0 +1,125 @@
+package perf
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+// sameAddress reports whether a net.IP and a netip.Addr name the same
+// address. net.ParseIP stores IPv4 in 16-byte IPv4-mapped form, so the
+// netip side is unmapped before comparing.
+func sameAddress(ip net.IP, addr netip.Addr) bool {
+	fromIP, ok := netip.AddrFromSlice(ip)
+	return ok && fromIP.Unmap() == addr.Unmap()
+}
+
+func TestIPParsersAgree(t *testing.T) {
+	cases := []string{
+		"0.0.0.0",
+		"127.0.0.1",
+		"192.168.1.254",
+		"255.255.255.255",
+		"10.0.0.1",
+		"::",
+		"::1",
+		"2001:db8::1",
+		"2001:0db8:0000:0000:0000:ff00:0042:8329",
+		"fe80::1ff:fe23:4567:890a",
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+		"::ffff:192.0.2.128",
+		"64:ff9b::192.0.2.33",
+	}
+	for _, s := range cases {
+		ip := net.ParseIP(s)
+		addr, err := netip.ParseAddr(s)
+		if ip == nil || err != nil {
+			t.Fatalf("%q: net.ParseIP = %v, netip.ParseAddr error = %v", s, ip, err)
+		}
+		if !sameAddress(ip, addr) {
+			t.Errorf("%q: net.ParseIP = %v, netip.ParseAddr = %v", s, ip, addr)
+		}
+		if (ip.To4() != nil) != addr.Unmap().Is4() {
+			t.Errorf("%q: net reports IPv4 %t, netip reports %t", s, ip.To4() != nil, addr.Unmap().Is4())
+		}
+		if back, err := netip.ParseAddr(addr.String()); err != nil || back != addr {
+			t.Errorf("%q: String round trip gave %v, %v", s, back, err)
+		}
+	}
+}
+
+func TestIPParsersRejectInvalid(t *testing.T) {
+	for _, s := range []string{"", "1.2.3", "1.2.3.4.5", "256.1.1.1", "01.2.3.4", "1.2.3.-4", "::g", "1:2:3:4:5:6:7:8:9", "2001:db8:::1", "example.com", " 1.2.3.4"} {
+		if ip := net.ParseIP(s); ip != nil {
+			t.Errorf("net.ParseIP(%q) = %v, want nil", s, ip)
+		}
+		if addr, err := netip.ParseAddr(s); err == nil {
+			t.Errorf("netip.ParseAddr(%q) = %v, want an error", s, addr)
+		}
+	}
+	// Zones are the one difference in what the two accept.
+	if net.ParseIP("fe80::1%eth0") != nil {
+		t.Error("net.ParseIP accepted a zone")
+	}
+	if addr, err := netip.ParseAddr("fe80::1%eth0"); err != nil || addr.Zone() != "eth0" {
+		t.Errorf("netip.ParseAddr with zone = %v, %v", addr, err)
+	}
+}
+
+func TestNetipParseDoesNotAllocate(t *testing.T) {
+	for _, s := range []string{"203.0.113.7", "2001:db8:85a3::8a2e:370:7334"} {
+		if allocs := testing.AllocsPerRun(100, func() { netip.ParseAddr(s) }); allocs != 0 {
+			t.Errorf("netip.ParseAddr(%q) allocated %v times", s, allocs)
+		}
+	}
+}
+
+// ipBenchInputs resemble the client addresses in an access log: mostly
+// IPv4, one in four IPv6.
+var (
+	ipBenchInputs = makeIPInputs(10_000)
+	ipNetSink     []net.IP
+	ipAddrSink    []netip.Addr
+)
+
+func makeIPInputs(n int) []string {
+	rng := rand.New(rand.NewPCG(17, 23))
+	out := make([]string, n)
+	for i := range out {
+		if i%4 == 3 {
+			out[i] = fmt.Sprintf("2001:db8:%x:%x::%x", rng.IntN(1<<16), rng.IntN(1<<16), rng.IntN(1<<16))
+		} else {
+			out[i] = fmt.Sprintf("%d.%d.%d.%d", 1+rng.IntN(223), rng.IntN(256), rng.IntN(256), 1+rng.IntN(254))
+		}
+	}
+	return out
+}
+
+// Each operation parses all 10,000 addresses and keeps them, as a log
+// ingester filling records would. The result slices are allocated once.
+
+func BenchmarkParseIPNet(b *testing.B) {
+	ips := make([]net.IP, len(ipBenchInputs))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, s := range ipBenchInputs {
+			ips[j] = net.ParseIP(s)
+		}
+	}
+	ipNetSink = ips
+}
+
+func BenchmarkParseIPNetip(b *testing.B) {
+	addrs := make([]netip.Addr, len(ipBenchInputs))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, s := range ipBenchInputs {
+			addrs[j], _ = netip.ParseAddr(s)
+		}
+	}
+	ipAddrSink = addrs
+}
@@ -0,0 +1,291 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+var (
+	errJSONNotInt = errors.New("json: number is not an int64")
+	errJSONSyntax = errors.New("json: invalid syntax")
+)
+
+// The three decoders below reduce every number in a document to one int64
+// checksum, the XOR of all values. XOR ignores order, so the map-based
+// decoders can walk their maps in any order and still agree with the
+// scanner when every value survives decoding intact.
+
+// xorJSONIntsAny decodes doc into any, which turns every number into a
+// boxed float64. Integers above 2^53 are rounded on the way in.
+func xorJSONIntsAny(doc []byte) (int64, error) {
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return 0, err
+	}
+	return xorJSONValue(v)
+}
+
+// xorJSONIntsNumber keeps each number's text as a json.Number and parses it
+// with strconv, so values are exact, but each is still a boxed string.
+func xorJSONIntsNumber(doc []byte) (int64, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return 0, err
+	}
+	return xorJSONValue(v)
+}
+
+func xorJSONValue(v any) (int64, error) {
+	switch v := v.(type) {
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, errJSONNotInt
+		}
+		return int64(v), nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, errJSONNotInt
+		}
+		return n, nil
+	case map[string]any:
+		var x int64
+		for _, e := range v {
+			n, err := xorJSONValue(e)
+			if err != nil {
+				return 0, err
+			}
+			x ^= n
+		}
+		return x, nil
+	case []any:
+		var x int64
+		for _, e := range v {
+			n, err := xorJSONValue(e)
+			if err != nil {
+				return 0, err
+			}
+			x ^= n
+		}
+		return x, nil
+	}
+	return 0, nil
+}
+
+// XORJSONInts walks doc once and parses each number straight from the
+// input bytes into an int64. Nothing is boxed and nothing is rounded. It
+// tokenizes like the scanner in json-scanner_test.go, checking tokens but
+// not document structure, except that strings are skipped rather than
+// decoded because only the numbers are wanted.
+func XORJSONInts(doc []byte) (int64, error) {
+	var x int64
+	for i := 0; i < len(doc); {
+		c := doc[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ':' || c == ',',
+			c == '{' || c == '}' || c == '[' || c == ']':
+			i++
+		case c == '"':
+			n, err := skipJSONString(doc[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(doc) && isJSONNumberByte(doc[j]) {
+				j++
+			}
+			// The string conversion doesn't allocate: ParseInt doesn't keep
+			// its argument, so short numbers are copied to a stack buffer.
+			n, err := strconv.ParseInt(string(doc[i:j]), 10, 64)
+			if err != nil {
+				return 0, errJSONNotInt
+			}
+			x ^= n
+			i = j
+		case bytes.HasPrefix(doc[i:], []byte("true")), bytes.HasPrefix(doc[i:], []byte("null")):
+			i += 4
+		case bytes.HasPrefix(doc[i:], []byte("false")):
+			i += 5
+		default:
+			return 0, errJSONSyntax
+		}
+	}
+	return x, nil
+}
+
+func isJSONNumberByte(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-'
+}
+
+// skipJSONString returns the length of the string starting at
+// data[0] == '"'. Escapes are checked but not decoded, so digits inside a
+// string are never taken for a number.
+func skipJSONString(data []byte) (int, error) {
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			return i + 1, nil
+		case '\\':
+			if i+1 == len(data) {
+				return 0, errJSONSyntax
+			}
+			switch data[i+1] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				i++
+			case 'u':
+				if i+6 > len(data) {
+					return 0, errJSONSyntax
+				}
+				if _, err := strconv.ParseUint(string(data[i+2:i+6]), 16, 16); err != nil {
+					return 0, errJSONSyntax
+				}
+				i += 5
+			default:
+				return 0, errJSONSyntax
+			}
+		}
+	}
+	return 0, errJSONSyntax
+}
+
+func TestJSONIntsLargeValuesRoundTrip(t *testing.T) {
+	for _, want := range []int64{0, -1, 1 << 53, 1<<53 + 1, 9_007_199_254_740_993, 1_700_000_000_123_456_789, math.MaxInt64, math.MinInt64} {
+		doc, err := json.Marshal(map[string]any{"id": want})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := XORJSONInts(doc); err != nil || got != want {
+			t.Errorf("scanner decoded %s as %d (err %v), want %d", doc, got, err, want)
+		}
+		if got, err := xorJSONIntsNumber(doc); err != nil || got != want {
+			t.Errorf("json.Number decoded %s as %d (err %v), want %d", doc, got, err, want)
+		}
+	}
+}
+
+func TestJSONIntsFloat64LosesPrecision(t *testing.T) {
+	doc := []byte(`{"id": 9007199254740993}`) // 2^53 + 1
+	got, err := xorJSONIntsAny(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 9_007_199_254_740_992 {
+		t.Fatalf("decoding into any gave %d; expected it to round to 2^53", got)
+	}
+	// MaxInt64 rounds up to 2^63, which doesn't fit in an int64 at all.
+	if _, err := xorJSONIntsAny([]byte(`[9223372036854775807]`)); err != errJSONNotInt {
+		t.Fatalf("MaxInt64 through float64: error %v, want errJSONNotInt", err)
+	}
+}
+
+func TestJSONIntsDecodersAgree(t *testing.T) {
+	for i, doc := range jsonIntBenchDocs[:50] {
+		want, err := XORJSONInts(doc)
+		if err != nil {
+			t.Fatalf("doc %d: scanner: %v", i, err)
+		}
+		if got, err := xorJSONIntsNumber(doc); err != nil || got != want {
+			t.Fatalf("doc %d: json.Number gave %d (err %v), scanner %d", i, got, err, want)
+		}
+	}
+	// The same documents restricted to values below 2^53 decode correctly
+	// through float64 too.
+	small := []byte(`{"id": 9007199254740991, "items": [{"qty": 3, "price": -1999}], "n": []}`)
+	want, _ := XORJSONInts(small)
+	if got, err := xorJSONIntsAny(small); err != nil || got != want {
+		t.Fatalf("float64 path gave %d (err %v), want %d", got, err, want)
+	}
+}
+
+func TestJSONIntsScannerRejectsNonIntegers(t *testing.T) {
+	for _, doc := range []string{`[1.5]`, `{"a": 1e3}`, `[99999999999999999999]`, `[-]`} {
+		if _, err := XORJSONInts([]byte(doc)); err != errJSONNotInt {
+			t.Errorf("%s: error %v, want errJSONNotInt", doc, err)
+		}
+	}
+	for _, doc := range []string{`[1, nope]`, `{"a": "unterminated`, `{"a\q": 1}`, `["\u12"]`, `["\`} {
+		if _, err := XORJSONInts([]byte(doc)); err != errJSONSyntax {
+			t.Errorf("%s: error %v, want errJSONSyntax", doc, err)
+		}
+	}
+}
+
+func TestJSONIntsScannerSkipsStrings(t *testing.T) {
+	// Digits, escaped quotes, and brackets inside strings aren't numbers.
+	doc := []byte(`{"note": "order \"42\" [3, 4] \u0031\\", "n": 7, "ok": true, "x": null, "y": false}`)
+	if got, err := XORJSONInts(doc); err != nil || got != 7 {
+		t.Fatalf("got %d (err %v), want 7", got, err)
+	}
+	if got, err := xorJSONIntsNumber(doc); err != nil || got != 7 {
+		t.Fatalf("json.Number gave %d (err %v), want 7", got, err)
+	}
+}
+
+func TestJSONIntsScannerDoesNotAllocate(t *testing.T) {
+	doc := jsonIntBenchDocs[0]
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := XORJSONInts(doc); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("XORJSONInts allocated %v times", allocs)
+	}
+}
+
+// jsonIntBenchDocs are order events: 64-bit IDs and nanosecond timestamps,
+// most of them above 2^53, plus a line item array of small integers.
+var (
+	jsonIntBenchDocs = func() [][]byte {
+		rng := rand.New(rand.NewPCG(21, 21))
+		docs := make([][]byte, 1000)
+		for i := range docs {
+			var buf bytes.Buffer
+			fmt.Fprintf(&buf, `{"order_id":%d,"account_id":%d,"created_ns":%d,"items":[`,
+				rng.Int64(), rng.Int64N(1<<40), 1_700_000_000_000_000_000+rng.Int64N(1e17))
+			for j := range 8 {
+				if j > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(&buf, `{"sku":%d,"qty":%d,"price_cents":%d}`, rng.Int64N(1e9), 1+rng.IntN(10), rng.IntN(100_000))
+			}
+			fmt.Fprintf(&buf, `],"discount_cents":%d}`, -rng.IntN(5000))
+			docs[i] = buf.Bytes()
+		}
+		return docs
+	}()
+	jsonIntSink int64
+)
+
+// Each operation decodes all 1,000 documents.
+
+func benchmarkJSONInts(b *testing.B, decode func(doc []byte) (int64, error)) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var x int64
+		for _, doc := range jsonIntBenchDocs {
+			n, err := decode(doc)
+			if err != nil {
+				b.Fatal(err)
+			}
+			x ^= n
+		}
+		jsonIntSink = x
+	}
+}
+
+func BenchmarkJSONIntsUnmarshalAny(b *testing.B) { benchmarkJSONInts(b, xorJSONIntsAny) }
+
+func BenchmarkJSONIntsUseNumber(b *testing.B) { benchmarkJSONInts(b, xorJSONIntsNumber) }
+
+func BenchmarkJSONIntsScanner(b *testing.B) { benchmarkJSONInts(b, XORJSONInts) }
@@ -0,0 +1,295 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type JSONTokenKind uint8
+
+const (
+	JSONDelim JSONTokenKind = iota
+	JSONString
+	JSONNumber
+	JSONBool
+	JSONNull
+)
+
+// JSONToken is a single token reported by JSONScanner. Value points either
+// into the input or into the scanner's scratch buffer and is only valid for
+// the duration of the callback.
+type JSONToken struct {
+	Kind  JSONTokenKind
+	Value []byte
+}
+
+var errJSONSyntax = errors.New("json: invalid syntax")
+
+// JSONScanner tokenizes JSON without boxing values into interfaces. Like
+// json.Decoder.Token it skips ':' and ','; unlike it, it doesn't validate
+// the overall document structure.
+type JSONScanner struct {
+	scratch []byte
+}
+
+var jsonScannerPool = sync.Pool{
+	New: func() any {
+		return &JSONScanner{scratch: make([]byte, 0, 256)}
+	},
+}
+
+// ScanJSON tokenizes data with a pooled scanner.
+func ScanJSON(data []byte, fn func(JSONToken)) error {
+	s := jsonScannerPool.Get().(*JSONScanner)
+	err := s.Scan(data, fn)
+	s.scratch = s.scratch[:0]
+	jsonScannerPool.Put(s)
+	return err
+}
+
+func (s *JSONScanner) Scan(data []byte, fn func(JSONToken)) error {
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ':' || c == ',':
+			i++
+		case c == '{' || c == '}' || c == '[' || c == ']':
+			fn(JSONToken{Kind: JSONDelim, Value: data[i : i+1]})
+			i++
+		case c == '"':
+			v, n, err := s.scanString(data[i:])
+			if err != nil {
+				return err
+			}
+			fn(JSONToken{Kind: JSONString, Value: v})
+			i += n
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(data) && isJSONNumberByte(data[j]) {
+				j++
+			}
+			fn(JSONToken{Kind: JSONNumber, Value: data[i:j]})
+			i = j
+		case bytes.HasPrefix(data[i:], []byte("true")):
+			fn(JSONToken{Kind: JSONBool, Value: data[i : i+4]})
+			i += 4
+		case bytes.HasPrefix(data[i:], []byte("false")):
+			fn(JSONToken{Kind: JSONBool, Value: data[i : i+5]})
+			i += 5
+		case bytes.HasPrefix(data[i:], []byte("null")):
+			fn(JSONToken{Kind: JSONNull, Value: data[i : i+4]})
+			i += 4
+		default:
+			return errJSONSyntax
+		}
+	}
+	return nil
+}
+
+func isJSONNumberByte(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-'
+}
+
+// scanString returns the unquoted string starting at data[0] == '"' and the
+// number of input bytes consumed. Strings without escapes are returned as a
+// slice of the input; escaped strings are decoded into the scratch buffer.
+func (s *JSONScanner) scanString(data []byte) ([]byte, int, error) {
+	for i := 1; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			return data[1:i], i + 1, nil
+		case '\\':
+			return s.unescape(data, i)
+		}
+	}
+	return nil, 0, errJSONSyntax
+}
+
+func (s *JSONScanner) unescape(data []byte, i int) ([]byte, int, error) {
+	buf := append(s.scratch[:0], data[1:i]...)
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case c == '"':
+			s.scratch = buf
+			return buf, i + 1, nil
+		case c != '\\':
+			buf = append(buf, c)
+			i++
+			continue
+		}
+		if i+1 >= len(data) {
+			return nil, 0, errJSONSyntax
+		}
+		switch e := data[i+1]; e {
+		case '"', '\\', '/':
+			buf = append(buf, e)
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, n := decodeJSONEscapedRune(data[i:])
+			if n == 0 {
+				return nil, 0, errJSONSyntax
+			}
+			buf = utf8.AppendRune(buf, r)
+			i += n
+			continue
+		default:
+			return nil, 0, errJSONSyntax
+		}
+		i += 2
+	}
+	return nil, 0, errJSONSyntax
+}
+
+// decodeJSONEscapedRune decodes \uXXXX, including UTF-16 surrogate pairs,
+// and returns the rune and the number of bytes consumed (0 on error).
+func decodeJSONEscapedRune(data []byte) (rune, int) {
+	hex4 := func(b []byte) rune {
+		if len(b) < 6 || b[0] != '\\' || b[1] != 'u' {
+			return -1
+		}
+		v, err := strconv.ParseUint(string(b[2:6]), 16, 16)
+		if err != nil {
+			return -1
+		}
+		return rune(v)
+	}
+	r := hex4(data)
+	if r < 0 {
+		return 0, 0
+	}
+	if utf16.IsSurrogate(r) {
+		if r2 := hex4(data[6:]); r2 >= 0 {
+			if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+				return dec, 12
+			}
+		}
+		return utf8.RuneError, 6
+	}
+	return r, 6
+}
+
+// decoderTokens renders the standard decoder's token stream for comparison.
+func decoderTokens(data []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch v := tok.(type) {
+		case json.Delim:
+			out = append(out, "delim:"+v.String())
+		case string:
+			out = append(out, "string:"+v)
+		case json.Number:
+			out = append(out, "number:"+v.String())
+		case bool:
+			out = append(out, "bool:"+strconv.FormatBool(v))
+		case nil:
+			out = append(out, "null:null")
+		}
+	}
+}
+
+func scannerTokens(data []byte) ([]string, error) {
+	kinds := [...]string{"delim", "string", "number", "bool", "null"}
+	var out []string
+	err := ScanJSON(data, func(tok JSONToken) {
+		out = append(out, kinds[tok.Kind]+":"+string(tok.Value))
+	})
+	return out, err
+}
+
+var jsonScanDocument = func() []byte {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"user-%d","active":%t,"score":%d.5,"tags":["a","b\n"],"note":null}`, i, i, i%2 == 0, i%100)
+	}
+	sb.WriteByte(']')
+	return []byte(sb.String())
+}()
+
+func TestJSONScannerMatchesDecoder(t *testing.T) {
+	docs := map[string]string{
+		"object":  `{"a": 1, "b": "two", "c": true, "d": false, "e": null}`,
+		"array":   `[1, -2, 3.25, 4e10, -5.5E-3, [], {}]`,
+		"nested":  `{"outer": {"inner": [ {"x": [1, [2, [3]]]} ]}}`,
+		"strings": `["plain", "quote\"d", "back\\slash", "tab\tnl\n", "é \u00e9 \ud83d\ude00", "\/"]`,
+		"empty":   `""`,
+		"large":   string(jsonScanDocument),
+	}
+	for name, doc := range docs {
+		want, err := decoderTokens([]byte(doc))
+		if err != nil {
+			t.Fatalf("%s: decoder: %v", name, err)
+		}
+		got, err := scannerTokens([]byte(doc))
+		if err != nil {
+			t.Fatalf("%s: scanner: %v", name, err)
+		}
+		if strings.Join(got, "\x00") != strings.Join(want, "\x00") {
+			t.Errorf("%s:\n got  %q\n want %q", name, got, want)
+		}
+	}
+}
+
+func TestJSONScannerRejectsGarbage(t *testing.T) {
+	for _, doc := range []string{`"unterminated`, `[nope]`, `"bad \x escape"`, `"\u12"`} {
+		if err := ScanJSON([]byte(doc), func(JSONToken) {}); err == nil {
+			t.Errorf("%q: expected error", doc)
+		}
+	}
+}
+
+var jsonTokenCount int
+
+func BenchmarkJSONDecoderToken(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		dec := json.NewDecoder(bytes.NewReader(jsonScanDocument))
+		n := 0
+		for {
+			_, err := dec.Token()
+			if err != nil {
+				break
+			}
+			n++
+		}
+		jsonTokenCount = n
+	}
+}
+
+func BenchmarkJSONPooledScanner(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		n := 0
+		_ = ScanJSON(jsonScanDocument, func(JSONToken) { n++ })
+		jsonTokenCount = n
+	}
+}
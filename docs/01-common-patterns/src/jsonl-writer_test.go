@@ -0,0 +1,340 @@
+package perf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"reflect"
+	"strconv"
+	"testing"
+	"unicode/utf8"
+)
+
+// exportEvent is one record of a log or audit export.
+type exportEvent struct {
+	ID        int64    `json:"id"`
+	Time      int64    `json:"ts"`
+	Level     string   `json:"level"`
+	Message   string   `json:"msg"`
+	LatencyUs int64    `json:"latency_us"`
+	OK        bool     `json:"ok"`
+	Tags      []string `json:"tags"`
+}
+
+// --- json.Marshal per object ---
+
+func writeJSONLinesMarshal(w *bufio.Writer, events []exportEvent) error {
+	for i := range events {
+		data, err := json.Marshal(&events[i])
+		if err != nil {
+			return err
+		}
+		w.Write(data)
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
+
+// --- json.Encoder over the same writer ---
+
+func writeJSONLinesEncoder(w *bufio.Writer, events []exportEvent) error {
+	enc := json.NewEncoder(w) // Encode appends the '\n' itself
+	for i := range events {
+		if err := enc.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// --- Appending into a reused buffer ---
+
+// jsonLinesFlushSize is how much encoded output JSONLinesWriter collects
+// before writing it out.
+const jsonLinesFlushSize = 64 * 1024
+
+// JSONLinesWriter encodes events straight into a buffer it keeps, writing
+// the buffer to the destination whenever it fills. Reset points it at a new
+// destination without giving up the buffer.
+type JSONLinesWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewJSONLinesWriter(w io.Writer) *JSONLinesWriter {
+	return &JSONLinesWriter{w: w, buf: make([]byte, 0, jsonLinesFlushSize+1024)}
+}
+
+// Reset discards any unflushed output and switches to w.
+func (j *JSONLinesWriter) Reset(w io.Writer) {
+	j.w = w
+	j.buf = j.buf[:0]
+}
+
+// WriteEvent appends ev as one line of JSON.
+func (j *JSONLinesWriter) WriteEvent(ev *exportEvent) error {
+	j.buf = appendExportEvent(j.buf, ev)
+	j.buf = append(j.buf, '\n')
+	if len(j.buf) >= jsonLinesFlushSize {
+		return j.Flush()
+	}
+	return nil
+}
+
+// Flush writes any buffered output.
+func (j *JSONLinesWriter) Flush() error {
+	if len(j.buf) == 0 {
+		return nil
+	}
+	_, err := j.w.Write(j.buf)
+	j.buf = j.buf[:0]
+	return err
+}
+
+// appendExportEvent produces the same bytes as json.Marshal(ev).
+func appendExportEvent(dst []byte, ev *exportEvent) []byte {
+	dst = append(dst, `{"id":`...)
+	dst = strconv.AppendInt(dst, ev.ID, 10)
+	dst = append(dst, `,"ts":`...)
+	dst = strconv.AppendInt(dst, ev.Time, 10)
+	dst = append(dst, `,"level":`...)
+	dst = appendJSONString(dst, ev.Level)
+	dst = append(dst, `,"msg":`...)
+	dst = appendJSONString(dst, ev.Message)
+	dst = append(dst, `,"latency_us":`...)
+	dst = strconv.AppendInt(dst, ev.LatencyUs, 10)
+	dst = append(dst, `,"ok":`...)
+	dst = strconv.AppendBool(dst, ev.OK)
+	dst = append(dst, `,"tags":`...)
+	if ev.Tags == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, tag := range ev.Tags {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, tag)
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, '}')
+}
+
+// appendJSONString quotes s the way encoding/json does by default: HTML
+// characters, control characters, U+2028 and U+2029 are escaped, and
+// invalid UTF-8 becomes U+FFFD.
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\b':
+				dst = append(dst, `\b`...)
+			case '\f':
+				dst = append(dst, `\f`...)
+			case '\n':
+				dst = append(dst, `\n`...)
+			case '\r':
+				dst = append(dst, `\r`...)
+			case '\t':
+				dst = append(dst, `\t`...)
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\uFFFD"...)
+		case r == '\u2028' || r == '\u2029':
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+func TestAppendExportEventMatchesMarshal(t *testing.T) {
+	tricky := []string{
+		"", "plain", `quote " and \ backslash`, "tab\tnewline\nreturn\r",
+		"\b\f\x00\x1f\x7f", "<script>&amp;</script>", "héllo, 世界 🎉",
+		"line\u2028para\u2029end", "bad \xff utf-8 \xe2\x82", "\xed\xa0\x80 surrogate",
+	}
+	events := []exportEvent{{}, {Tags: []string{}}}
+	for i, s := range tricky {
+		events = append(events, exportEvent{ID: -int64(i), Time: 1 << 62, Level: s, Message: s, OK: i%2 == 0, Tags: []string{s, "x"}})
+	}
+	events = append(events, jsonlBenchEvents[:1000]...)
+	for i := range events {
+		want, err := json.Marshal(&events[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := appendExportEvent(nil, &events[i]); !bytes.Equal(got, want) {
+			t.Fatalf("event %d:\n got  %s\n want %s", i, got, want)
+		}
+	}
+}
+
+func TestJSONLinesRoundTrip(t *testing.T) {
+	var marshal, encoder, reused bytes.Buffer
+	if err := writeJSONLinesMarshal(bufio.NewWriter(&marshal), jsonlBenchEvents); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONLinesEncoder(bufio.NewWriter(&encoder), jsonlBenchEvents); err != nil {
+		t.Fatal(err)
+	}
+	jw := NewJSONLinesWriter(nil)
+	for range 2 {
+		reused.Reset()
+		jw.Reset(&reused)
+		for i := range jsonlBenchEvents {
+			if err := jw.WriteEvent(&jsonlBenchEvents[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := jw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reused.Bytes(), marshal.Bytes()) || !bytes.Equal(encoder.Bytes(), marshal.Bytes()) {
+			t.Fatalf("outputs differ: marshal %d, encoder %d, reused %d bytes", marshal.Len(), encoder.Len(), reused.Len())
+		}
+	}
+
+	// Every line decodes back into the event it came from.
+	sc := bufio.NewScanner(&reused)
+	n := 0
+	for ; sc.Scan(); n++ {
+		var ev exportEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("line %d: %v", n+1, err)
+		}
+		if !reflect.DeepEqual(ev, jsonlBenchEvents[n]) {
+			t.Fatalf("line %d decoded to %+v, want %+v", n+1, ev, jsonlBenchEvents[n])
+		}
+	}
+	if n != len(jsonlBenchEvents) {
+		t.Fatalf("%d lines, want %d", n, len(jsonlBenchEvents))
+	}
+}
+
+func TestJSONLinesWriterResetDropsUnflushed(t *testing.T) {
+	var first, second bytes.Buffer
+	jw := NewJSONLinesWriter(&first)
+	_ = jw.WriteEvent(&exportEvent{ID: 1})
+	jw.Reset(&second)
+	_ = jw.WriteEvent(&exportEvent{ID: 2})
+	if err := jw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if first.Len() != 0 || !bytes.HasPrefix(second.Bytes(), []byte(`{"id":2,`)) || bytes.Count(second.Bytes(), []byte("\n")) != 1 {
+		t.Fatalf("first %q, second %q", first.String(), second.String())
+	}
+}
+
+func TestJSONLinesWriterDoesNotAllocate(t *testing.T) {
+	jw := NewJSONLinesWriter(io.Discard)
+	i := 0
+	allocs := testing.AllocsPerRun(10_000, func() {
+		if err := jw.WriteEvent(&jsonlBenchEvents[i%len(jsonlBenchEvents)]); err != nil {
+			t.Fatal(err)
+		}
+		i++
+	})
+	if allocs != 0 {
+		t.Fatalf("WriteEvent allocated %v times", allocs)
+	}
+}
+
+// jsonlBenchEvents are 100,000 export events, about 15 MB of JSON lines.
+var jsonlBenchEvents = func() []exportEvent {
+	rng := rand.New(rand.NewPCG(31, 31))
+	levels := []string{"debug", "info", "info", "info", "warn", "error"}
+	messages := []string{
+		"request completed", "cache miss for key %q", `upstream said "retry later"`,
+		"slow query\tplan=seq_scan", "user <admin> logged in", "payload rejected: invalid utf-8",
+	}
+	tags := []string{"api", "eu-west-1", "canary", "v2", "batch", "internal"}
+	out := make([]exportEvent, 100_000)
+	for i := range out {
+		ev := exportEvent{
+			ID:        int64(i) + 1,
+			Time:      1_760_000_000_000 + int64(i)*37 + rng.Int64N(37),
+			Level:     levels[rng.IntN(len(levels))],
+			Message:   messages[rng.IntN(len(messages))] + " #" + strconv.Itoa(rng.IntN(10_000)),
+			LatencyUs: rng.Int64N(2_000_000),
+			OK:        rng.IntN(10) != 0,
+		}
+		if n := rng.IntN(len(tags) + 1); n > 0 {
+			ev.Tags = tags[:n]
+		}
+		out[i] = ev
+	}
+	return out
+}()
+
+// Each operation writes all 100,000 events to io.Discard.
+
+func BenchmarkJSONLinesMarshal(b *testing.B) {
+	w := bufio.NewWriterSize(io.Discard, jsonLinesFlushSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Reset(io.Discard)
+		if err := writeJSONLinesMarshal(w, jsonlBenchEvents); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONLinesEncoder(b *testing.B) {
+	w := bufio.NewWriterSize(io.Discard, jsonLinesFlushSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Reset(io.Discard)
+		if err := writeJSONLinesEncoder(w, jsonlBenchEvents); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONLinesReusedBuffer(b *testing.B) {
+	jw := NewJSONLinesWriter(io.Discard)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jw.Reset(io.Discard)
+		for j := range jsonlBenchEvents {
+			if err := jw.WriteEvent(&jsonlBenchEvents[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := jw.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
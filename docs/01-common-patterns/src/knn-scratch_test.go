@@ -0,0 +1,194 @@
+package perf
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// knnDataset holds n vectors of dim float32s in one flat array; vector i is
+// data[i*dim : (i+1)*dim].
+type knnDataset struct {
+	dim  int
+	data []float32
+}
+
+func (d *knnDataset) Len() int { return len(d.data) / d.dim }
+
+func (d *knnDataset) Vector(i int) []float32 { return d.data[i*d.dim : (i+1)*d.dim] }
+
+type knnNeighbor struct {
+	Index int
+	Dist  float32 // squared Euclidean distance
+}
+
+// knnCloser orders neighbors by distance, breaking ties by index so every
+// implementation agrees on the exact result.
+func knnCloser(a, b knnNeighbor) bool {
+	return a.Dist < b.Dist || (a.Dist == b.Dist && a.Index < b.Index)
+}
+
+func compareNeighbors(a, b knnNeighbor) int {
+	return cmp.Or(cmp.Compare(a.Dist, b.Dist), cmp.Compare(a.Index, b.Index))
+}
+
+func squaredDist(a, b []float32) float32 {
+	b = b[:len(a)]
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// knnSearch fills dists with the distance from q to every point, keeps the
+// k closest in best, a max-heap with the farthest kept neighbor at the
+// root, and returns them sorted closest first. Both query paths share it;
+// they differ only in where dists and best come from. best must be empty;
+// its capacity is reused.
+func knnSearch(d *knnDataset, q []float32, k int, dists []float32, best []knnNeighbor) []knnNeighbor {
+	for i := range dists {
+		dists[i] = squaredDist(q, d.Vector(i))
+	}
+	for i, dist := range dists {
+		c := knnNeighbor{Index: i, Dist: dist}
+		if len(best) < k {
+			best = append(best, c)
+			knnSiftUp(best, len(best)-1)
+		} else if k > 0 && knnCloser(c, best[0]) {
+			best[0] = c
+			knnSiftDown(best, 0)
+		}
+	}
+	slices.SortFunc(best, compareNeighbors)
+	return best
+}
+
+func knnSiftUp(h []knnNeighbor, i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !knnCloser(h[parent], h[i]) {
+			break
+		}
+		h[i], h[parent] = h[parent], h[i]
+		i = parent
+	}
+}
+
+func knnSiftDown(h []knnNeighbor, i int) {
+	for {
+		far := i
+		if l := 2*i + 1; l < len(h) && knnCloser(h[far], h[l]) {
+			far = l
+		}
+		if r := 2*i + 2; r < len(h) && knnCloser(h[far], h[r]) {
+			far = r
+		}
+		if far == i {
+			return
+		}
+		h[i], h[far] = h[far], h[i]
+		i = far
+	}
+}
+
+// knnQueryFresh allocates the distances buffer and result heap per query.
+func knnQueryFresh(d *knnDataset, q []float32, k int) []knnNeighbor {
+	return knnSearch(d, q, k, make([]float32, d.Len()), make([]knnNeighbor, 0, k))
+}
+
+// knnSearcher owns scratch space sized for its dataset. Results returned by
+// Query are valid until the next call; a searcher serves one goroutine.
+type knnSearcher struct {
+	data  *knnDataset
+	dists []float32
+	best  []knnNeighbor
+}
+
+func newKNNSearcher(d *knnDataset) *knnSearcher {
+	return &knnSearcher{data: d, dists: make([]float32, d.Len())}
+}
+
+func (s *knnSearcher) Query(q []float32, k int) []knnNeighbor {
+	s.best = knnSearch(s.data, q, k, s.dists, s.best[:0])
+	return s.best
+}
+
+// bruteForceKNN sorts every point by distance and takes the first k.
+func bruteForceKNN(d *knnDataset, q []float32, k int) []knnNeighbor {
+	all := make([]knnNeighbor, d.Len())
+	for i := range all {
+		all[i] = knnNeighbor{Index: i, Dist: squaredDist(q, d.Vector(i))}
+	}
+	slices.SortFunc(all, compareNeighbors)
+	return all[:min(k, len(all))]
+}
+
+// randomKNNDataset draws coordinates from a small integer grid so exact
+// distance ties occur and the tie-breaking rule is exercised.
+func randomKNNDataset(n, dim int, seed uint64) *knnDataset {
+	rng := rand.New(rand.NewPCG(seed, seed+11))
+	d := &knnDataset{dim: dim, data: make([]float32, n*dim)}
+	for i := range d.data {
+		d.data[i] = float32(rng.IntN(8))
+	}
+	return d
+}
+
+func TestKNNMatchesBruteForce(t *testing.T) {
+	d := randomKNNDataset(2000, 4, 1)
+	queries := randomKNNDataset(50, 4, 2)
+	s := newKNNSearcher(d)
+	for _, k := range []int{0, 1, 5, 32, 2000, 2500} {
+		for qi := 0; qi < queries.Len(); qi++ {
+			q := queries.Vector(qi)
+			want := bruteForceKNN(d, q, k)
+			if got := knnQueryFresh(d, q, k); !slices.Equal(got, want) {
+				t.Fatalf("k=%d query %d: fresh = %v, want %v", k, qi, got, want)
+			}
+			if got := s.Query(q, k); !slices.Equal(got, want) {
+				t.Fatalf("k=%d query %d: reused = %v, want %v", k, qi, got, want)
+			}
+		}
+	}
+}
+
+func TestKNNSearcherDoesNotAllocate(t *testing.T) {
+	d := randomKNNDataset(1000, 8, 3)
+	q := d.Vector(7)
+	s := newKNNSearcher(d)
+	s.Query(q, 10)
+	if allocs := testing.AllocsPerRun(100, func() { s.Query(q, 10) }); allocs != 0 {
+		t.Fatalf("reused searcher allocated %v times per query", allocs)
+	}
+}
+
+// Benchmarks search 10,000 embedding-sized vectors for the 10 nearest
+// neighbors of each of 256 query vectors in turn.
+const knnBenchK = 10
+
+var (
+	knnBenchData    = randomKNNDataset(10_000, 32, 4)
+	knnBenchQueries = randomKNNDataset(256, 32, 5)
+	knnIndexSink    int
+)
+
+func BenchmarkKNNFresh(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		res := knnQueryFresh(knnBenchData, knnBenchQueries.Vector(i%knnBenchQueries.Len()), knnBenchK)
+		knnIndexSink = res[0].Index
+	}
+}
+
+func BenchmarkKNNReusedScratch(b *testing.B) {
+	s := newKNNSearcher(knnBenchData)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res := s.Query(knnBenchQueries.Vector(i%knnBenchQueries.Len()), knnBenchK)
+		knnIndexSink = res[0].Index
+	}
+}
@@ -0,0 +1,224 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+const (
+	frameHeaderLen = 4
+	maxFrameLen    = 1 << 20
+)
+
+var (
+	// ErrFrameIncomplete means src ends before the frame does. A stream
+	// reader should read more data and try again.
+	ErrFrameIncomplete = errors.New("frame: incomplete")
+	ErrFrameTooLarge   = errors.New("frame: length exceeds limit")
+)
+
+// AppendFrame appends payload to dst preceded by its length as a 4-byte
+// big-endian integer. It doesn't check the length: the writers below reject
+// payloads over maxFrameLen before framing them, as ReadFrame would.
+func AppendFrame(dst, payload []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	return append(dst, payload...)
+}
+
+// ReadFrame decodes the first frame in src. The payload aliases src; rest is
+// what follows the frame. On ErrFrameIncomplete nothing is consumed.
+func ReadFrame(src []byte) (payload, rest []byte, err error) {
+	if len(src) < frameHeaderLen {
+		return nil, src, ErrFrameIncomplete
+	}
+	n := binary.BigEndian.Uint32(src)
+	if n > maxFrameLen {
+		return nil, src, ErrFrameTooLarge
+	}
+	end := frameHeaderLen + int(n)
+	if len(src) < end {
+		return nil, src, ErrFrameIncomplete
+	}
+	return src[frameHeaderLen:end], src[end:], nil
+}
+
+// frameAlloc is the allocating approach: one new buffer per message.
+func frameAlloc(payload []byte) []byte {
+	buf := make([]byte, frameHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	copy(buf[frameHeaderLen:], payload)
+	return buf
+}
+
+func writeFramesAlloc(w io.Writer, msgs [][]byte) error {
+	for _, m := range msgs {
+		if len(m) > maxFrameLen {
+			return ErrFrameTooLarge
+		}
+		if _, err := w.Write(frameAlloc(m)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFramesReused frames each message into buf, reusing its capacity, and
+// returns the buffer so the caller can keep it for the next batch.
+func writeFramesReused(w io.Writer, msgs [][]byte, buf []byte) ([]byte, error) {
+	for _, m := range msgs {
+		if len(m) > maxFrameLen {
+			return buf, ErrFrameTooLarge
+		}
+		buf = AppendFrame(buf[:0], m)
+		if _, err := w.Write(buf); err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+func makeFrameMessages(n int) [][]byte {
+	sizes := []int{0, 17, 64, 200, 512}
+	msgs := make([][]byte, n)
+	for i := range msgs {
+		m := make([]byte, sizes[i%len(sizes)])
+		for j := range m {
+			m[j] = byte(i + j)
+		}
+		msgs[i] = m
+	}
+	return msgs
+}
+
+func readAllFrames(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	var out [][]byte
+	for len(data) > 0 {
+		payload, rest, err := ReadFrame(data)
+		if err != nil {
+			t.Fatalf("ReadFrame after %d frames: %v", len(out), err)
+		}
+		out = append(out, payload)
+		data = rest
+	}
+	return out
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	msgs := makeFrameMessages(100)
+	var reused, alloc bytes.Buffer
+	if _, err := writeFramesReused(&reused, msgs, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFramesAlloc(&alloc, msgs); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reused.Bytes(), alloc.Bytes()) {
+		t.Fatal("reused and allocating framers produced different bytes")
+	}
+	got := readAllFrames(t, reused.Bytes())
+	if len(got) != len(msgs) {
+		t.Fatalf("decoded %d frames, want %d", len(got), len(msgs))
+	}
+	for i := range msgs {
+		if !bytes.Equal(got[i], msgs[i]) {
+			t.Fatalf("frame %d: got %d bytes, want %d", i, len(got[i]), len(msgs[i]))
+		}
+	}
+}
+
+func TestReadFramePartial(t *testing.T) {
+	frame := AppendFrame(nil, []byte("hello, frame"))
+	for cut := 0; cut < len(frame); cut++ {
+		payload, rest, err := ReadFrame(frame[:cut])
+		if !errors.Is(err, ErrFrameIncomplete) {
+			t.Fatalf("cut at %d: got err %v, want ErrFrameIncomplete", cut, err)
+		}
+		if payload != nil || len(rest) != cut {
+			t.Fatalf("cut at %d: incomplete read consumed input", cut)
+		}
+	}
+
+	// A stream reader accumulates bytes until the frame completes.
+	var pending []byte
+	for i := 0; i < len(frame); i++ {
+		pending = append(pending, frame[i])
+		payload, rest, err := ReadFrame(pending)
+		if i < len(frame)-1 {
+			if !errors.Is(err, ErrFrameIncomplete) {
+				t.Fatalf("byte %d: got err %v, want ErrFrameIncomplete", i, err)
+			}
+			continue
+		}
+		if err != nil || string(payload) != "hello, frame" || len(rest) != 0 {
+			t.Fatalf("complete frame: payload %q, rest %d bytes, err %v", payload, len(rest), err)
+		}
+	}
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	hdr := binary.BigEndian.AppendUint32(nil, maxFrameLen+1)
+	if _, _, err := ReadFrame(hdr); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("got %v, want ErrFrameTooLarge", err)
+	}
+}
+
+func TestWriteFramesTooLarge(t *testing.T) {
+	msgs := [][]byte{[]byte("ok"), make([]byte, maxFrameLen+1)}
+	var reused, alloc bytes.Buffer
+	if _, err := writeFramesReused(&reused, msgs, nil); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("reused framer: got %v, want ErrFrameTooLarge", err)
+	}
+	if err := writeFramesAlloc(&alloc, msgs); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("allocating framer: got %v, want ErrFrameTooLarge", err)
+	}
+	// Only the frame before the oversized one was written.
+	for name, out := range map[string][]byte{"reused": reused.Bytes(), "alloc": alloc.Bytes()} {
+		if got := readAllFrames(t, out); len(got) != 1 || string(got[0]) != "ok" {
+			t.Fatalf("%s framer wrote %d frames before failing, want 1", name, len(got))
+		}
+	}
+
+	// The largest frame ReadFrame accepts must be writable.
+	var out bytes.Buffer
+	if _, err := writeFramesReused(&out, [][]byte{make([]byte, maxFrameLen)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if payload, _, err := ReadFrame(out.Bytes()); err != nil || len(payload) != maxFrameLen {
+		t.Fatalf("max-size frame: %d bytes, %v", len(payload), err)
+	}
+}
+
+func TestAppendFrameReusedDoesNotAllocate(t *testing.T) {
+	msgs := makeFrameMessages(10)
+	w := io.Discard
+	buf, _ := writeFramesReused(w, msgs, nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = writeFramesReused(w, msgs, buf)
+	})
+	if allocs != 0 {
+		t.Fatalf("reused framing allocated %v times", allocs)
+	}
+}
+
+var frameBenchMessages = makeFrameMessages(1000)
+
+func BenchmarkFrameAllocPerMessage(b *testing.B) {
+	w := io.Discard
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = writeFramesAlloc(w, frameBenchMessages)
+	}
+}
+
+func BenchmarkFrameAppendReused(b *testing.B) {
+	w := io.Discard
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = writeFramesReused(w, frameBenchMessages, buf)
+	}
+}
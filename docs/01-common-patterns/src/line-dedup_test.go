@@ -0,0 +1,371 @@
+package perf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"testing"
+)
+
+// --- Per-line strings in a map ---
+
+// dedupLinesAlloc copies every line into a new string and keeps the unique
+// ones as map keys. The scanner's line limit is lifted so that, like
+// LineDeduper, it accepts lines of any length.
+func dedupLinesAlloc(r io.Reader, w io.Writer) error {
+	seen := make(map[string]struct{})
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, math.MaxInt)
+	bw := bufio.NewWriter(w)
+	for sc.Scan() {
+		line := sc.Text()
+		if _, ok := seen[line]; ok {
+			continue
+		}
+		seen[line] = struct{}{}
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// --- Reused hash set over an arena ---
+
+// dedupEntry locates a stored line in the arena. Entries whose lines hash
+// to the same value form a chain through next.
+type dedupEntry struct {
+	off, len int
+	next     int32 // index of the next entry with the same hash, or -1
+}
+
+// LineDeduper writes the first occurrence of each line. It keys a map by a
+// 64-bit hash and keeps the lines themselves in one arena so that a hash
+// match can be verified. Reset keeps every buffer, so a deduper processing
+// file after file stops allocating once it has seen the largest one.
+type LineDeduper struct {
+	hash    func([]byte) uint64
+	heads   map[uint64]int32 // hash -> first entry in its chain
+	entries []dedupEntry
+	arena   []byte
+	long    []byte // assembles lines longer than the read buffer
+	br      *bufio.Reader
+	bw      *bufio.Writer
+}
+
+var dedupSeed = maphash.MakeSeed()
+
+func NewLineDeduper() *LineDeduper {
+	return newLineDeduperHash(func(b []byte) uint64 { return maphash.Bytes(dedupSeed, b) })
+}
+
+// newLineDeduperHash lets tests substitute a hash that collides on purpose.
+func newLineDeduperHash(hash func([]byte) uint64) *LineDeduper {
+	return &LineDeduper{
+		hash:  hash,
+		heads: make(map[uint64]int32),
+		br:    bufio.NewReaderSize(nil, 64*1024),
+		bw:    bufio.NewWriterSize(nil, 64*1024),
+	}
+}
+
+// Reset forgets every line seen so far, keeping the allocated memory.
+func (d *LineDeduper) Reset() {
+	clear(d.heads)
+	d.entries = d.entries[:0]
+	d.arena = d.arena[:0]
+}
+
+// Dedup copies the unique lines of r to w, in the order they first appear,
+// each terminated by '\n'. Lines seen in earlier calls count as duplicates
+// until Reset.
+func (d *LineDeduper) Dedup(r io.Reader, w io.Writer) error {
+	d.br.Reset(r)
+	d.bw.Reset(w)
+	for {
+		line, err := d.readLine()
+		if len(line) > 0 || err == nil {
+			if d.insert(line) {
+				d.bw.Write(line)
+				d.bw.WriteByte('\n')
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return d.bw.Flush()
+}
+
+// readLine returns the next line without its '\n', or "\r\n" as
+// bufio.ScanLines strips it. The slice is valid until the next call.
+func (d *LineDeduper) readLine() ([]byte, error) {
+	line, err := d.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		d.long = append(d.long[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = d.br.ReadSlice('\n')
+			d.long = append(d.long, line...)
+		}
+		line = d.long
+	}
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, err
+}
+
+// insert reports whether line is new, storing it if so.
+func (d *LineDeduper) insert(line []byte) bool {
+	h := d.hash(line)
+	head, ok := d.heads[h]
+	if ok {
+		for i := head; i >= 0; i = d.entries[i].next {
+			e := d.entries[i]
+			if bytes.Equal(d.arena[e.off:e.off+e.len], line) {
+				return false
+			}
+		}
+	} else {
+		head = -1
+	}
+	d.heads[h] = int32(len(d.entries))
+	d.entries = append(d.entries, dedupEntry{off: len(d.arena), len: len(line), next: head})
+	d.arena = append(d.arena, line...)
+	return true
+}
+
+// referenceDedup is a plain in-memory version for the tests.
+func referenceDedup(lines []string) string {
+	seen := map[string]bool{}
+	var sb bytes.Buffer
+	for _, l := range lines {
+		if !seen[l] {
+			seen[l] = true
+			sb.WriteString(l + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func TestLineDedupFirstOccurrenceOrder(t *testing.T) {
+	in := "b\na\nb\nc\na\n\nd\n\nc\ne"
+	want := "b\na\nc\n\nd\ne\n"
+	var alloc, reused bytes.Buffer
+	if err := dedupLinesAlloc(bytes.NewReader([]byte(in)), &alloc); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewLineDeduper().Dedup(bytes.NewReader([]byte(in)), &reused); err != nil {
+		t.Fatal(err)
+	}
+	if alloc.String() != want || reused.String() != want {
+		t.Fatalf("alloc %q, reused %q, want %q", alloc.String(), reused.String(), want)
+	}
+}
+
+func TestLineDedupVerifiesHashCollisions(t *testing.T) {
+	// Every line of the same length collides, and the chain has to tell
+	// them apart by content.
+	d := newLineDeduperHash(func(b []byte) uint64 { return uint64(len(b)) })
+	lines := []string{"abc", "xyz", "abc", "pqr", "xyz", "ab", "cd", "ab", "pqr", ""}
+	var out bytes.Buffer
+	if err := d.Dedup(bytes.NewReader([]byte(fmt.Sprintln(joinLines(lines)))), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := referenceDedup(lines); out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+	if len(d.heads) != 3 || len(d.entries) != 6 {
+		t.Fatalf("%d hash heads and %d entries, want 3 and 6", len(d.heads), len(d.entries))
+	}
+}
+
+func TestLineDedupTooLongForBuffer(t *testing.T) {
+	big := string(bytes.Repeat([]byte("x"), 200_000))
+	lines := []string{big, "short", big, big + "y", "short"}
+	var alloc, reused bytes.Buffer
+	if err := dedupLinesAlloc(bytes.NewReader([]byte(joinLines(lines))), &alloc); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewLineDeduper().Dedup(bytes.NewReader([]byte(joinLines(lines))), &reused); err != nil {
+		t.Fatal(err)
+	}
+	want := referenceDedup(lines)
+	if alloc.String() != want || reused.String() != want {
+		t.Fatalf("alloc %d bytes, reused %d bytes, want %d", alloc.Len(), reused.Len(), len(want))
+	}
+}
+
+func TestLineDedupCRLF(t *testing.T) {
+	// "a\r\n" and "a\n" are the same line, and a lone "\r" at the end of
+	// the input is dropped, as bufio.ScanLines does.
+	in := "a\r\nb\na\nb\r\n\r\n\nc\r"
+	want := "a\nb\n\nc\n"
+	var alloc, reused bytes.Buffer
+	if err := dedupLinesAlloc(bytes.NewReader([]byte(in)), &alloc); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewLineDeduper().Dedup(bytes.NewReader([]byte(in)), &reused); err != nil {
+		t.Fatal(err)
+	}
+	if alloc.String() != want || reused.String() != want {
+		t.Fatalf("alloc %q, reused %q, want %q", alloc.String(), reused.String(), want)
+	}
+}
+
+func TestLineDedupMatchesMapOnLargeInput(t *testing.T) {
+	var alloc, reused bytes.Buffer
+	if err := dedupLinesAlloc(bytes.NewReader(dedupBenchInput), &alloc); err != nil {
+		t.Fatal(err)
+	}
+	d := NewLineDeduper()
+	for range 2 {
+		reused.Reset()
+		d.Reset()
+		if err := d.Dedup(bytes.NewReader(dedupBenchInput), &reused); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(alloc.Bytes(), reused.Bytes()) {
+			t.Fatalf("deduper output (%d bytes) differs from the map version (%d bytes)", reused.Len(), alloc.Len())
+		}
+	}
+	if got := bytes.Count(reused.Bytes(), []byte("\n")); got != dedupDistinct {
+		t.Fatalf("%d unique lines, want %d", got, dedupDistinct)
+	}
+}
+
+func TestLineDeduperReuseDoesNotAllocate(t *testing.T) {
+	d := NewLineDeduper()
+	r := bytes.NewReader(dedupBenchInput)
+	_ = d.Dedup(r, io.Discard)
+	allocs := testing.AllocsPerRun(3, func() {
+		d.Reset()
+		r.Reset(dedupBenchInput)
+		if err := d.Dedup(r, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("reused Dedup allocated %v times", allocs)
+	}
+}
+
+func joinLines(lines []string) string {
+	return string(bytes.Join(func() [][]byte {
+		out := make([][]byte, len(lines))
+		for i, l := range lines {
+			out[i] = []byte(l)
+		}
+		return out
+	}(), []byte("\n")))
+}
+
+// dedupBenchInput is a log of 500,000 lines with 100,000 distinct ones, in
+// random order, about 34 MB.
+const (
+	dedupLines    = 500_000
+	dedupDistinct = 100_000
+)
+
+var dedupBenchInput = func() []byte {
+	rng := rand.New(rand.NewPCG(23, 23))
+	distinct := make([]string, dedupDistinct)
+	for i := range distinct {
+		distinct[i] = fmt.Sprintf("level=info svc=api-%d path=/v1/items/%d status=%d user=%x",
+			rng.IntN(20), i, 200+rng.IntN(4)*100, rng.Uint32())
+	}
+	var buf bytes.Buffer
+	// Every distinct line appears at least once; the rest are repeats.
+	for i := 0; i < dedupLines; i++ {
+		if i < dedupDistinct {
+			buf.WriteString(distinct[i])
+		} else {
+			buf.WriteString(distinct[rng.IntN(dedupDistinct)])
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}()
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// Each operation deduplicates the whole input. heap-B is the growth in heap
+// at the end of an operation, measured with runtime.ReadMemStats.
+
+func BenchmarkLineDedupMapStrings(b *testing.B) {
+	var peak uint64
+	r := bytes.NewReader(dedupBenchInput)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		r.Reset(dedupBenchInput)
+		runtime.GC()
+		base := heapAlloc()
+		b.StartTimer()
+
+		if err := dedupLinesAlloc(r, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+}
+
+func BenchmarkLineDedupReusedHashSet(b *testing.B) {
+	var peak uint64
+	r := bytes.NewReader(dedupBenchInput)
+	// Size the deduper with one pass, as a long-running process would after
+	// its first file. state-B is the memory it then keeps between files.
+	runtime.GC()
+	start := heapAlloc()
+	d := NewLineDeduper()
+	if err := d.Dedup(r, io.Discard); err != nil {
+		b.Fatal(err)
+	}
+	runtime.GC()
+	state := heapAlloc() - start
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		r.Reset(dedupBenchInput)
+		runtime.GC()
+		base := heapAlloc()
+		b.StartTimer()
+
+		d.Reset()
+		if err := d.Dedup(r, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+	b.ReportMetric(float64(state), "state-B")
+}
@@ -0,0 +1,212 @@
+package perf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// --- Naive: a string per line, strings.Split, and re-joining quoted fields ---
+
+func parseLogLineNaive(line string) []string {
+	var fields []string
+	var quoted []string
+	for _, tok := range strings.Split(line, " ") {
+		switch {
+		case quoted != nil:
+			quoted = append(quoted, tok)
+			if strings.HasSuffix(tok, `"`) {
+				fields = append(fields, strings.TrimSuffix(strings.Join(quoted, " "), `"`))
+				quoted = nil
+			}
+		case strings.HasPrefix(tok, `"`):
+			if len(tok) >= 2 && strings.HasSuffix(tok, `"`) {
+				fields = append(fields, tok[1:len(tok)-1])
+			} else {
+				quoted = []string{tok[1:]}
+			}
+		case tok != "":
+			fields = append(fields, tok)
+		}
+	}
+	if quoted != nil {
+		fields = append(fields, strings.Join(quoted, " "))
+	}
+	return fields
+}
+
+func parseLogNaive(r io.Reader, fn func([]string)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fn(parseLogLineNaive(sc.Text()))
+	}
+	return sc.Err()
+}
+
+// --- LogParser: reused line buffer and fields slice, zero-copy fields ---
+
+// LogParser splits log lines into space-separated fields. A field that starts
+// with '"' runs to the next '"' and may contain spaces. The slices returned
+// by Fields point into the parser's line buffer and are only valid until
+// the next call to Next.
+type LogParser struct {
+	sc     *bufio.Scanner
+	fields [][]byte
+}
+
+func NewLogParser(r io.Reader) *LogParser {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	return &LogParser{sc: sc, fields: make([][]byte, 0, 16)}
+}
+
+func (p *LogParser) Next() bool {
+	if !p.sc.Scan() {
+		return false
+	}
+	p.fields = splitLogFields(p.fields[:0], p.sc.Bytes())
+	return true
+}
+
+func (p *LogParser) Fields() [][]byte { return p.fields }
+
+func (p *LogParser) Err() error { return p.sc.Err() }
+
+func splitLogFields(dst [][]byte, line []byte) [][]byte {
+	for i := 0; i < len(line); {
+		switch line[i] {
+		case ' ':
+			i++
+		case '"':
+			end := bytes.IndexByte(line[i+1:], '"')
+			if end < 0 {
+				return append(dst, line[i+1:])
+			}
+			dst = append(dst, line[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := bytes.IndexByte(line[i:], ' ')
+			if end < 0 {
+				return append(dst, line[i:])
+			}
+			dst = append(dst, line[i:i+end])
+			i += end
+		}
+	}
+	return dst
+}
+
+func logParserFields(p *LogParser) []string {
+	out := make([]string, len(p.Fields()))
+	for i, f := range p.Fields() {
+		out[i] = string(f)
+	}
+	return out
+}
+
+func TestLogParserFields(t *testing.T) {
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{`2024-05-01T10:00:00Z INFO auth user=alice`, []string{"2024-05-01T10:00:00Z", "INFO", "auth", "user=alice"}},
+		{`ts WARN "disk almost full" pct=97`, []string{"ts", "WARN", "disk almost full", "pct=97"}},
+		{`ts ERROR "a  double  spaced" x`, []string{"ts", "ERROR", "a  double  spaced", "x"}},
+		{`  leading   and trailing  `, []string{"leading", "and", "trailing"}},
+		{`empty "" quoted`, []string{"empty", "", "quoted"}},
+		{`msg="not a quoted field"`, []string{`msg="not`, "a", "quoted", `field"`}},
+		{`ts "unterminated quote here`, []string{"ts", "unterminated quote here"}},
+		{``, nil},
+	}
+	for _, c := range cases {
+		var got []string
+		p := NewLogParser(strings.NewReader(c.line + "\n"))
+		if p.Next() {
+			got = logParserFields(p)
+		}
+		if len(got) == 0 && len(c.want) == 0 {
+			got = nil
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("LogParser(%q) = %q, want %q", c.line, got, c.want)
+		}
+		naive := parseLogLineNaive(c.line)
+		if !slices.Equal(naive, c.want) {
+			t.Errorf("naive(%q) = %q, want %q", c.line, naive, c.want)
+		}
+	}
+}
+
+func TestLogParserMatchesNaive(t *testing.T) {
+	var naive [][]string
+	if err := parseLogNaive(bytes.NewReader(logInput), func(f []string) { naive = append(naive, f) }); err != nil {
+		t.Fatal(err)
+	}
+	p := NewLogParser(bytes.NewReader(logInput))
+	i := 0
+	for ; p.Next(); i++ {
+		if got := logParserFields(p); !slices.Equal(got, naive[i]) {
+			t.Fatalf("line %d: %q, want %q", i, got, naive[i])
+		}
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(naive) {
+		t.Fatalf("parsed %d lines, want %d", i, len(naive))
+	}
+}
+
+func TestSplitLogFieldsReusesSlice(t *testing.T) {
+	line := []byte(`2024-05-01T10:00:00Z INFO "request served" method=GET status=200`)
+	fields := make([][]byte, 0, 16)
+	allocs := testing.AllocsPerRun(1000, func() {
+		fields = splitLogFields(fields[:0], line)
+	})
+	if allocs != 0 {
+		t.Fatalf("splitLogFields allocated %v times", allocs)
+	}
+}
+
+var logInput = func() []byte {
+	var buf bytes.Buffer
+	levels := []string{"INFO", "WARN", "ERROR", "DEBUG"}
+	for i := 0; i < 100_000; i++ {
+		fmt.Fprintf(&buf, `2024-05-01T10:%02d:%02dZ %s api "request served in %dms" method=GET path=/v1/items/%d status=200`+"\n",
+			i/60%60, i%60, levels[i%4], i%250, i)
+	}
+	return buf.Bytes()
+}()
+
+var logFieldBytes int
+
+func BenchmarkLogParseNaiveSplit(b *testing.B) {
+	b.SetBytes(int64(len(logInput)))
+	for i := 0; i < b.N; i++ {
+		n := 0
+		_ = parseLogNaive(bytes.NewReader(logInput), func(fields []string) {
+			for _, f := range fields {
+				n += len(f)
+			}
+		})
+		logFieldBytes = n
+	}
+}
+
+func BenchmarkLogParseReused(b *testing.B) {
+	b.SetBytes(int64(len(logInput)))
+	for i := 0; i < b.N; i++ {
+		n := 0
+		p := NewLogParser(bytes.NewReader(logInput))
+		for p.Next() {
+			for _, f := range p.Fields() {
+				n += len(f)
+			}
+		}
+		logFieldBytes = n
+	}
+}
@@ -0,0 +1,289 @@
+package perf
+
+import (
+	"container/list"
+	"testing"
+)
+
+// --- Naive LRU: one list element allocated per insert ---
+
+type naiveLRUEntry struct {
+	key   uint64
+	value uint64
+}
+
+type NaiveLRU struct {
+	capacity int
+	order    *list.List
+	items    map[uint64]*list.Element
+}
+
+// NewNaiveLRU panics if capacity is less than 1: a cache that can hold
+// nothing has no entry to evict.
+func NewNaiveLRU(capacity int) *NaiveLRU {
+	if capacity < 1 {
+		panic("NewNaiveLRU: capacity must be at least 1")
+	}
+	return &NaiveLRU{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[uint64]*list.Element),
+	}
+}
+
+func (c *NaiveLRU) Get(key uint64) (uint64, bool) {
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*naiveLRUEntry).value, true
+	}
+	return 0, false
+}
+
+func (c *NaiveLRU) Put(key, value uint64) {
+	if e, ok := c.items[key]; ok {
+		e.Value.(*naiveLRUEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	if c.order.Len() == c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*naiveLRUEntry).key)
+	}
+	c.items[key] = c.order.PushFront(&naiveLRUEntry{key: key, value: value})
+}
+
+// --- Preallocated LRU: fixed node array with an intrusive list ---
+
+// lruNode links are indexes into LRU.nodes rather than pointers, so the
+// whole list lives in one allocation and holds no pointers for the GC to scan.
+type lruNode struct {
+	key, value uint64
+	prev, next int32
+}
+
+// LRU is a fixed-capacity cache. All nodes are allocated up front; inserting
+// into a full cache reuses the evicted node instead of allocating a new one.
+type LRU struct {
+	nodes []lruNode
+	items map[uint64]int32
+	head  int32 // most recently used, -1 if empty
+	tail  int32 // least recently used, -1 if empty
+}
+
+// NewLRU panics if capacity is less than 1, like NewNaiveLRU.
+func NewLRU(capacity int) *LRU {
+	if capacity < 1 {
+		panic("NewLRU: capacity must be at least 1")
+	}
+	return &LRU{
+		nodes: make([]lruNode, 0, capacity),
+		items: make(map[uint64]int32, capacity),
+		head:  -1,
+		tail:  -1,
+	}
+}
+
+func (c *LRU) Len() int { return len(c.items) }
+
+func (c *LRU) Get(key uint64) (uint64, bool) {
+	i, ok := c.items[key]
+	if !ok {
+		return 0, false
+	}
+	c.moveToFront(i)
+	return c.nodes[i].value, true
+}
+
+func (c *LRU) Put(key, value uint64) {
+	if i, ok := c.items[key]; ok {
+		c.nodes[i].value = value
+		c.moveToFront(i)
+		return
+	}
+
+	var i int32
+	if len(c.nodes) < cap(c.nodes) {
+		i = int32(len(c.nodes))
+		c.nodes = append(c.nodes, lruNode{})
+	} else {
+		i = c.tail
+		c.unlink(i)
+		delete(c.items, c.nodes[i].key)
+	}
+	c.nodes[i].key, c.nodes[i].value = key, value
+	c.pushFront(i)
+	c.items[key] = i
+}
+
+func (c *LRU) moveToFront(i int32) {
+	if c.head == i {
+		return
+	}
+	c.unlink(i)
+	c.pushFront(i)
+}
+
+func (c *LRU) pushFront(i int32) {
+	n := &c.nodes[i]
+	n.prev, n.next = -1, c.head
+	if c.head >= 0 {
+		c.nodes[c.head].prev = i
+	}
+	c.head = i
+	if c.tail < 0 {
+		c.tail = i
+	}
+}
+
+func (c *LRU) unlink(i int32) {
+	n := &c.nodes[i]
+	if n.prev >= 0 {
+		c.nodes[n.prev].next = n.next
+	} else {
+		c.head = n.next
+	}
+	if n.next >= 0 {
+		c.nodes[n.next].prev = n.prev
+	} else {
+		c.tail = n.prev
+	}
+}
+
+// keys returns the keys from most to least recently used.
+func (c *LRU) keys() []uint64 {
+	var out []uint64
+	for i := c.head; i >= 0; i = c.nodes[i].next {
+		out = append(out, c.nodes[i].key)
+	}
+	return out
+}
+
+type lruCache interface {
+	Get(key uint64) (uint64, bool)
+	Put(key, value uint64)
+}
+
+func TestLRUEvictionOrder(t *testing.T) {
+	for name, c := range map[string]lruCache{"naive": NewNaiveLRU(3), "prealloc": NewLRU(3)} {
+		c.Put(1, 10)
+		c.Put(2, 20)
+		c.Put(3, 30)
+		c.Get(1)     // order: 1 3 2
+		c.Put(4, 40) // evicts 2
+		if _, ok := c.Get(2); ok {
+			t.Errorf("%s: key 2 should have been evicted", name)
+		}
+		c.Put(3, 33) // update refreshes 3: order 3 4 1
+		c.Put(5, 50) // evicts 1
+		if _, ok := c.Get(1); ok {
+			t.Errorf("%s: key 1 should have been evicted", name)
+		}
+		for k, want := range map[uint64]uint64{3: 33, 4: 40, 5: 50} {
+			if v, ok := c.Get(k); !ok || v != want {
+				t.Errorf("%s: Get(%d) = %d, %v; want %d, true", name, k, v, ok, want)
+			}
+		}
+	}
+}
+
+func TestLRURecencyList(t *testing.T) {
+	c := NewLRU(4)
+	for k := uint64(1); k <= 4; k++ {
+		c.Put(k, k)
+	}
+	c.Get(2)
+	c.Put(5, 5)
+	want := []uint64{5, 2, 4, 3}
+	got := c.keys()
+	if len(got) != len(want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("keys = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLRUCapacityBounds(t *testing.T) {
+	for name, build := range map[string]func(int) lruCache{
+		"naive":    func(n int) lruCache { return NewNaiveLRU(n) },
+		"prealloc": func(n int) lruCache { return NewLRU(n) },
+	} {
+		for _, capacity := range []int{0, -1} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s: capacity %d did not panic", name, capacity)
+					}
+				}()
+				build(capacity)
+			}()
+		}
+
+		// The smallest cache evicts on every new key.
+		c := build(1)
+		c.Put(1, 10)
+		c.Put(2, 20)
+		if _, ok := c.Get(1); ok {
+			t.Errorf("%s: capacity 1 kept key 1 after inserting key 2", name)
+		}
+		if v, ok := c.Get(2); !ok || v != 20 {
+			t.Errorf("%s: Get(2) = %d, %v; want 20, true", name, v, ok)
+		}
+	}
+}
+
+func TestLRUCapacityAndAllocs(t *testing.T) {
+	const capacity = 128
+	c := NewLRU(capacity)
+	for k := uint64(0); k < 10*capacity; k++ {
+		c.Put(k, k)
+		if c.Len() > capacity {
+			t.Fatalf("len %d exceeds capacity %d", c.Len(), capacity)
+		}
+	}
+	if cap(c.nodes) != capacity {
+		t.Fatalf("node array grew to %d", cap(c.nodes))
+	}
+
+	k := uint64(10 * capacity)
+	allocs := testing.AllocsPerRun(1000, func() {
+		c.Put(k, k)
+		c.Get(k - 1)
+		k++
+	})
+	if allocs != 0 {
+		t.Fatalf("steady-state Put/Get allocated %v times", allocs)
+	}
+}
+
+const (
+	lruCapacity = 4096
+	lruKeySpace = 3 * lruCapacity
+)
+
+// lruWorkload drives a mixed get/put pattern: a miss triggers a put.
+func lruWorkload(b *testing.B, c lruCache) {
+	x := uint64(1)
+	for i := 0; i < b.N; i++ {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+		k := x % lruKeySpace
+		if _, ok := c.Get(k); !ok {
+			c.Put(k, x)
+		}
+	}
+}
+
+func BenchmarkLRUNaive(b *testing.B) {
+	b.ReportAllocs()
+	lruWorkload(b, NewNaiveLRU(lruCapacity))
+}
+
+func BenchmarkLRUPrealloc(b *testing.B) {
+	b.ReportAllocs()
+	lruWorkload(b, NewLRU(lruCapacity))
+}
@@ -0,0 +1,174 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// Matrix is a dense row-major matrix backed by one flat slice: element
+// (i, j) lives at data[i*cols+j].
+type Matrix struct {
+	rows, cols int
+	data       []float64
+}
+
+func NewMatrix(rows, cols int) *Matrix {
+	return &Matrix{rows: rows, cols: cols, data: make([]float64, rows*cols)}
+}
+
+func (m *Matrix) At(i, j int) float64 { return m.data[i*m.cols+j] }
+
+// transposeNaive allocates the result and walks the source row by row, so
+// every write to the result lands one full row (cols*8 bytes) after the
+// previous one.
+func transposeNaive(src *Matrix) *Matrix {
+	dst := NewMatrix(src.cols, src.rows)
+	transposeInto(dst, src)
+	return dst
+}
+
+func transposeInto(dst, src *Matrix) {
+	for i := 0; i < src.rows; i++ {
+		for j := 0; j < src.cols; j++ {
+			dst.data[j*dst.cols+i] = src.data[i*src.cols+j]
+		}
+	}
+}
+
+// transposeBlock is the tile edge. A 32x32 tile of float64 is 8 KB, so the
+// source tile and the destination tile fit in L1 together.
+const transposeBlock = 32
+
+// TransposeBlocked writes the transpose of src into dst, which must be
+// cols x rows. It works tile by tile so that the cache lines touched in dst
+// are reused for a whole tile instead of being evicted after one write.
+func TransposeBlocked(dst, src *Matrix) {
+	if dst.rows != src.cols || dst.cols != src.rows {
+		panic("TransposeBlocked: dst has the wrong shape")
+	}
+	for ii := 0; ii < src.rows; ii += transposeBlock {
+		iEnd := min(ii+transposeBlock, src.rows)
+		for jj := 0; jj < src.cols; jj += transposeBlock {
+			jEnd := min(jj+transposeBlock, src.cols)
+			for i := ii; i < iEnd; i++ {
+				row := src.data[i*src.cols : i*src.cols+jEnd]
+				for j := jj; j < jEnd; j++ {
+					dst.data[j*dst.cols+i] = row[j]
+				}
+			}
+		}
+	}
+}
+
+func randomMatrix(rows, cols int, seed uint64) *Matrix {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	m := NewMatrix(rows, cols)
+	for i := range m.data {
+		m.data[i] = rng.NormFloat64()
+	}
+	return m
+}
+
+func checkTranspose(t *testing.T, name string, got, src *Matrix) {
+	t.Helper()
+	if got.rows != src.cols || got.cols != src.rows {
+		t.Fatalf("%s: shape %dx%d, want %dx%d", name, got.rows, got.cols, src.cols, src.rows)
+	}
+	for i := 0; i < src.rows; i++ {
+		for j := 0; j < src.cols; j++ {
+			if got.At(j, i) != src.At(i, j) {
+				t.Fatalf("%s: (%d,%d) = %v, want %v", name, j, i, got.At(j, i), src.At(i, j))
+			}
+		}
+	}
+}
+
+func TestTransposeSmall(t *testing.T) {
+	src := &Matrix{rows: 2, cols: 3, data: []float64{1, 2, 3, 4, 5, 6}}
+	want := []float64{1, 4, 2, 5, 3, 6}
+	dst := NewMatrix(3, 2)
+	TransposeBlocked(dst, src)
+	for i, v := range want {
+		if dst.data[i] != v || transposeNaive(src).data[i] != v {
+			t.Fatalf("transpose of 2x3 = %v, want %v", dst.data, want)
+		}
+	}
+}
+
+func TestTransposeMatchesDefinition(t *testing.T) {
+	// Shapes below, at, and across tile edges, including ones that leave
+	// partial tiles on both axes.
+	shapes := [][2]int{{1, 1}, {1, 100}, {100, 1}, {31, 33}, {32, 32}, {64, 96}, {97, 45}, {257, 130}}
+	for _, s := range shapes {
+		src := randomMatrix(s[0], s[1], uint64(s[0]*1000+s[1]))
+		checkTranspose(t, "naive", transposeNaive(src), src)
+		dst := NewMatrix(s[1], s[0])
+		TransposeBlocked(dst, src)
+		checkTranspose(t, "blocked", dst, src)
+	}
+}
+
+func TestTransposeTwiceIsIdentity(t *testing.T) {
+	src := randomMatrix(150, 70, 5)
+	tmp, back := NewMatrix(70, 150), NewMatrix(150, 70)
+	TransposeBlocked(tmp, src)
+	TransposeBlocked(back, tmp)
+	for i, v := range src.data {
+		if back.data[i] != v {
+			t.Fatalf("element %d changed after two transposes: %v, want %v", i, back.data[i], v)
+		}
+	}
+}
+
+func TestTransposeBlockedRejectsWrongShape(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("TransposeBlocked accepted a destination of the wrong shape")
+		}
+	}()
+	TransposeBlocked(NewMatrix(4, 4), NewMatrix(4, 5))
+}
+
+func TestTransposeBlockedDoesNotAllocate(t *testing.T) {
+	src := randomMatrix(128, 96, 9)
+	dst := NewMatrix(96, 128)
+	if allocs := testing.AllocsPerRun(10, func() { TransposeBlocked(dst, src) }); allocs != 0 {
+		t.Fatalf("TransposeBlocked allocated %v times", allocs)
+	}
+}
+
+const transposeN = 2048
+
+var (
+	transposeSrc  = randomMatrix(transposeN, transposeN, 1)
+	transposeSink *Matrix
+)
+
+// Each operation transposes one 2048x2048 matrix (32 MB).
+
+func BenchmarkTransposeNaiveFresh(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		transposeSink = transposeNaive(transposeSrc)
+	}
+}
+
+func BenchmarkTransposeNaiveReused(b *testing.B) {
+	dst := NewMatrix(transposeN, transposeN)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transposeInto(dst, transposeSrc)
+	}
+	transposeSink = dst
+}
+
+func BenchmarkTransposeBlockedReused(b *testing.B) {
+	dst := NewMatrix(transposeN, transposeN)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		TransposeBlocked(dst, transposeSrc)
+	}
+	transposeSink = dst
+}
@@ -0,0 +1,339 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// movingMedian adds a value to a sliding window of the last w values and
+// returns the median of the window. Until w values have arrived, the window
+// holds all of them. With an even count, the median is the mean of the two
+// middle values. NaN is not supported.
+type movingMedian interface {
+	Add(v float64) float64
+}
+
+// --- Re-sorting the window ---
+
+// SortMedian keeps the window in a ring and sorts a copy on every step.
+type SortMedian struct {
+	ring  []float64
+	next  int
+	reuse bool
+	buf   []float64
+}
+
+// NewSortMedian returns a sorting median. With reuse set, the copy is made
+// into one scratch buffer instead of a fresh slice per step. It panics if
+// window is less than 1: an empty window has no median.
+func NewSortMedian(window int, reuse bool) *SortMedian {
+	if window < 1 {
+		panic("NewSortMedian: window must be at least 1")
+	}
+	return &SortMedian{ring: make([]float64, 0, window), reuse: reuse, buf: make([]float64, 0, window)}
+}
+
+func (m *SortMedian) Add(v float64) float64 {
+	if len(m.ring) < cap(m.ring) {
+		m.ring = append(m.ring, v)
+	} else {
+		m.ring[m.next] = v
+		m.next = (m.next + 1) % len(m.ring)
+	}
+	var sorted []float64
+	if m.reuse {
+		sorted = append(m.buf[:0], m.ring...)
+	} else {
+		sorted = slices.Clone(m.ring)
+	}
+	slices.Sort(sorted)
+	return middleOfSorted(sorted)
+}
+
+func middleOfSorted(s []float64) float64 {
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// --- Two indexed heaps ---
+
+// HeapMedian splits the window between a max-heap of the lower half and a
+// min-heap of the upper half, so the median is at the top of one or both.
+// The heaps store slots of the ring rather than values, and pos tracks where
+// each slot sits in its heap, so the value leaving the window can be removed
+// in O(log w) without a search. All storage is allocated up front.
+type HeapMedian struct {
+	vals      []float64 // ring of window values, by slot
+	pos       []int32   // slot's index in its heap
+	inLow     []bool    // whether the slot is in the low heap
+	low, high []int32   // slot indices; low is a max-heap, high a min-heap
+	next, n   int
+}
+
+// NewHeapMedian panics if window is less than 1, like NewSortMedian.
+func NewHeapMedian(window int) *HeapMedian {
+	if window < 1 {
+		panic("NewHeapMedian: window must be at least 1")
+	}
+	return &HeapMedian{
+		vals:  make([]float64, window),
+		pos:   make([]int32, window),
+		inLow: make([]bool, window),
+		low:   make([]int32, 0, window),
+		high:  make([]int32, 0, window),
+	}
+}
+
+func (m *HeapMedian) Add(v float64) float64 {
+	slot := int32(m.next)
+	if m.n == len(m.vals) {
+		m.remove(slot)
+	} else {
+		m.n++
+	}
+	m.next = (m.next + 1) % len(m.vals)
+	m.vals[slot] = v
+
+	if len(m.low) == 0 || v <= m.vals[m.low[0]] {
+		m.push(true, slot)
+	} else {
+		m.push(false, slot)
+	}
+	// Keep len(low) == len(high) or len(high)+1.
+	for len(m.low) > len(m.high)+1 {
+		m.push(false, m.pop(true))
+	}
+	for len(m.high) > len(m.low) {
+		m.push(true, m.pop(false))
+	}
+
+	if m.n%2 == 1 {
+		return m.vals[m.low[0]]
+	}
+	return (m.vals[m.low[0]] + m.vals[m.high[0]]) / 2
+}
+
+func (m *HeapMedian) heap(low bool) *[]int32 {
+	if low {
+		return &m.low
+	}
+	return &m.high
+}
+
+// above reports whether slot a belongs above slot b in the given heap.
+func (m *HeapMedian) above(low bool, a, b int32) bool {
+	if low {
+		return m.vals[a] > m.vals[b]
+	}
+	return m.vals[a] < m.vals[b]
+}
+
+func (m *HeapMedian) set(low bool, i int, slot int32) {
+	(*m.heap(low))[i] = slot
+	m.pos[slot] = int32(i)
+	m.inLow[slot] = low
+}
+
+func (m *HeapMedian) push(low bool, slot int32) {
+	h := m.heap(low)
+	*h = append(*h, slot)
+	m.set(low, len(*h)-1, slot)
+	m.siftUp(low, len(*h)-1)
+}
+
+func (m *HeapMedian) pop(low bool) int32 {
+	top := (*m.heap(low))[0]
+	m.removeAt(low, 0)
+	return top
+}
+
+func (m *HeapMedian) remove(slot int32) {
+	m.removeAt(m.inLow[slot], int(m.pos[slot]))
+}
+
+// removeAt moves the last element into position i and restores the heap in
+// whichever direction it's out of order.
+func (m *HeapMedian) removeAt(low bool, i int) {
+	h := m.heap(low)
+	last := len(*h) - 1
+	if i != last {
+		m.set(low, i, (*h)[last])
+	}
+	*h = (*h)[:last]
+	if i < last {
+		m.siftDown(low, i)
+		m.siftUp(low, i)
+	}
+}
+
+func (m *HeapMedian) siftUp(low bool, i int) {
+	h := *m.heap(low)
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !m.above(low, h[i], h[parent]) {
+			return
+		}
+		a, b := h[i], h[parent]
+		m.set(low, i, b)
+		m.set(low, parent, a)
+		i = parent
+	}
+}
+
+func (m *HeapMedian) siftDown(low bool, i int) {
+	h := *m.heap(low)
+	for {
+		best := i
+		for _, c := range [2]int{2*i + 1, 2*i + 2} {
+			if c < len(h) && m.above(low, h[c], h[best]) {
+				best = c
+			}
+		}
+		if best == i {
+			return
+		}
+		a, b := h[i], h[best]
+		m.set(low, i, b)
+		m.set(low, best, a)
+		i = best
+	}
+}
+
+// referenceMedians sorts each window from scratch.
+func referenceMedians(stream []float64, window int) []float64 {
+	out := make([]float64, len(stream))
+	for i := range stream {
+		w := slices.Clone(stream[max(0, i-window+1) : i+1])
+		slices.Sort(w)
+		out[i] = middleOfSorted(w)
+	}
+	return out
+}
+
+func checkMovingMedian(t *testing.T, name string, m movingMedian, stream []float64, window int) {
+	t.Helper()
+	want := referenceMedians(stream, window)
+	for i, v := range stream {
+		if got := m.Add(v); got != want[i] {
+			t.Fatalf("%s, window %d: step %d median = %v, want %v", name, window, i, got, want[i])
+		}
+	}
+}
+
+func TestMovingMedianSmall(t *testing.T) {
+	stream := []float64{5, 1, 4, 2, 3, 9}
+	m := NewHeapMedian(3)
+	for i, want := range []float64{5, 3, 4, 2, 3, 3} {
+		if got := m.Add(stream[i]); got != want {
+			t.Fatalf("step %d median = %v, want %v", i, got, want)
+		}
+	}
+	m = NewHeapMedian(4)
+	for i, want := range []float64{5, 3, 4, 3, 2.5, 3.5} {
+		if got := m.Add(stream[i]); got != want {
+			t.Fatalf("window 4: step %d median = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMovingMedianMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewPCG(4, 4))
+	random := make([]float64, 3000)
+	for i := range random {
+		random[i] = rng.NormFloat64()
+	}
+	// Few distinct values exercise ties between the heaps.
+	ties := make([]float64, 3000)
+	for i := range ties {
+		ties[i] = float64(rng.IntN(5))
+	}
+	ascending, descending := make([]float64, 1000), make([]float64, 1000)
+	for i := range ascending {
+		ascending[i], descending[i] = float64(i), float64(-i)
+	}
+	streams := map[string][]float64{"random": random, "ties": ties, "ascending": ascending, "descending": descending}
+	for name, stream := range streams {
+		for _, window := range []int{1, 2, 3, 4, 7, 64, 101, 500} {
+			checkMovingMedian(t, name+" heaps", NewHeapMedian(window), stream, window)
+			checkMovingMedian(t, name+" sort", NewSortMedian(window, true), stream, window)
+			checkMovingMedian(t, name+" sort clone", NewSortMedian(window, false), stream, window)
+		}
+	}
+}
+
+func TestMovingMedianWindowBounds(t *testing.T) {
+	for name, build := range map[string]func(int) movingMedian{
+		"heaps": func(w int) movingMedian { return NewHeapMedian(w) },
+		"sort":  func(w int) movingMedian { return NewSortMedian(w, true) },
+	} {
+		for _, window := range []int{0, -1} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s: window %d did not panic", name, window)
+					}
+				}()
+				build(window)
+			}()
+		}
+	}
+}
+
+func TestHeapMedianDoesNotAllocate(t *testing.T) {
+	m := NewHeapMedian(101)
+	v := 0.0
+	allocs := testing.AllocsPerRun(1000, func() {
+		m.Add(v)
+		v = float64(int(v*7+3) % 1000)
+	})
+	if allocs != 0 {
+		t.Fatalf("Add allocated %v times", allocs)
+	}
+}
+
+const (
+	medianWindow    = 256
+	medianStreamLen = 100_000
+)
+
+var (
+	medianStream = func() []float64 {
+		rng := rand.New(rand.NewPCG(8, 8))
+		out := make([]float64, medianStreamLen)
+		level := 100.0
+		for i := range out {
+			level += rng.NormFloat64()
+			out[i] = level + 5*rng.NormFloat64()
+		}
+		return out
+	}()
+	medianSink float64
+)
+
+// Each operation streams 100,000 values through a 256-value window.
+
+func benchmarkMovingMedian(b *testing.B, newMedian func() movingMedian) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := newMedian()
+		for _, v := range medianStream {
+			medianSink = m.Add(v)
+		}
+	}
+}
+
+func BenchmarkMovingMedianSortClone(b *testing.B) {
+	benchmarkMovingMedian(b, func() movingMedian { return NewSortMedian(medianWindow, false) })
+}
+
+func BenchmarkMovingMedianSortScratch(b *testing.B) {
+	benchmarkMovingMedian(b, func() movingMedian { return NewSortMedian(medianWindow, true) })
+}
+
+func BenchmarkMovingMedianHeaps(b *testing.B) {
+	benchmarkMovingMedian(b, func() movingMedian { return NewHeapMedian(medianWindow) })
+}
@@ -0,0 +1,248 @@
+package perf
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"sync"
+	"testing"
+)
+
+// formPart is one parsed part. Body is only valid for the duration of the
+// handler call in the pooled parser.
+type formPart struct {
+	Name, FileName, ContentType string
+	Body                        []byte
+}
+
+// parseMultipartFresh reads every part body with io.ReadAll, allocating a
+// new, growing slice per part.
+func parseMultipartFresh(body []byte, boundary string, handle func(*formPart)) error {
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(p)
+		if err != nil {
+			return err
+		}
+		handle(&formPart{
+			Name:        p.FormName(),
+			FileName:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Body:        data,
+		})
+	}
+}
+
+// maxPooledPartBuffer keeps one large upload from pinning a large buffer in
+// the pool.
+const maxPooledPartBuffer = 256 << 10
+
+var partBufferPool = sync.Pool{
+	New: func() any { return bytes.NewBuffer(make([]byte, 0, 4096)) },
+}
+
+func acquirePartBuffer() *bytes.Buffer { return partBufferPool.Get().(*bytes.Buffer) }
+
+func releasePartBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledPartBuffer {
+		return
+	}
+	buf.Reset()
+	partBufferPool.Put(buf)
+}
+
+// parseMultipartPooled reads each part body into a pooled buffer that is
+// recycled as soon as the handler returns. One buffer serves every part of
+// a request.
+func parseMultipartPooled(body []byte, boundary string, handle func(*formPart)) error {
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	buf := acquirePartBuffer()
+	defer releasePartBuffer(buf)
+	var part formPart
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		buf.Reset()
+		if _, err := buf.ReadFrom(p); err != nil {
+			return err
+		}
+		part = formPart{
+			Name:        p.FormName(),
+			FileName:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Body:        buf.Bytes(),
+		}
+		handle(&part)
+	}
+}
+
+type testFormPart struct {
+	name, fileName, contentType string
+	body                        []byte
+}
+
+// buildMultipart encodes parts with multipart.Writer.
+func buildMultipart(parts []testFormPart) ([]byte, string) {
+	var out bytes.Buffer
+	w := multipart.NewWriter(&out)
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		disp := fmt.Sprintf(`form-data; name=%q`, p.name)
+		if p.fileName != "" {
+			disp += fmt.Sprintf(`; filename=%q`, p.fileName)
+		}
+		h.Set("Content-Disposition", disp)
+		if p.contentType != "" {
+			h.Set("Content-Type", p.contentType)
+		}
+		pw, _ := w.CreatePart(h)
+		pw.Write(p.body)
+	}
+	w.Close()
+	return out.Bytes(), w.Boundary()
+}
+
+func uploadParts(seed int) []testFormPart {
+	file := make([]byte, 1024+seed%512)
+	for i := range file {
+		file[i] = byte(seed + i*7)
+	}
+	return []testFormPart{
+		{name: "user_id", body: []byte(fmt.Sprint(10_000 + seed))},
+		{name: "title", body: []byte("holiday photos")},
+		{name: "avatar", fileName: "avatar.png", contentType: "image/png", body: file},
+		{name: "note", body: []byte("ok")},
+	}
+}
+
+// checkParsedParts encodes want, parses it back, and reports the first
+// difference. It returns an error rather than failing the test so it can
+// run on any goroutine.
+func checkParsedParts(parse func([]byte, string, func(*formPart)) error, want []testFormPart) error {
+	body, boundary := buildMultipart(want)
+	i := 0
+	var mismatch error
+	err := parse(body, boundary, func(p *formPart) {
+		defer func() { i++ }()
+		if mismatch != nil {
+			return
+		}
+		if i >= len(want) {
+			mismatch = fmt.Errorf("unexpected extra part %q", p.Name)
+			return
+		}
+		w := want[i]
+		if p.Name != w.name || p.FileName != w.fileName || p.ContentType != w.contentType {
+			mismatch = fmt.Errorf("part %d: got (%q, %q, %q), want (%q, %q, %q)",
+				i, p.Name, p.FileName, p.ContentType, w.name, w.fileName, w.contentType)
+		} else if !bytes.Equal(p.Body, w.body) {
+			mismatch = fmt.Errorf("part %d (%s): body of %d bytes differs from the %d expected", i, p.Name, len(p.Body), len(w.body))
+		}
+	})
+	switch {
+	case err != nil:
+		return err
+	case mismatch != nil:
+		return mismatch
+	case i != len(want):
+		return fmt.Errorf("parsed %d parts, want %d", i, len(want))
+	}
+	return nil
+}
+
+func TestMultipartParsers(t *testing.T) {
+	for seed := 0; seed < 20; seed++ {
+		if err := checkParsedParts(parseMultipartFresh, uploadParts(seed)); err != nil {
+			t.Fatalf("fresh, form %d: %v", seed, err)
+		}
+		if err := checkParsedParts(parseMultipartPooled, uploadParts(seed)); err != nil {
+			t.Fatalf("pooled, form %d: %v", seed, err)
+		}
+	}
+}
+
+func TestMultipartPooledNoCrossPartContamination(t *testing.T) {
+	// Parts shrink so a reused buffer would expose leftovers from the
+	// previous, larger part if it weren't reset.
+	parts := []testFormPart{
+		{name: "big", body: bytes.Repeat([]byte("A"), 8000)},
+		{name: "medium", body: bytes.Repeat([]byte("B"), 300)},
+		{name: "empty", body: nil},
+		{name: "small", body: []byte("c")},
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := checkParsedParts(parseMultipartPooled, parts); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestReleasePartBufferDropsOversized(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, 2*maxPooledPartBuffer))
+	buf.WriteString("large")
+	releasePartBuffer(buf)
+	if buf.Len() == 0 {
+		t.Fatal("oversized buffer should be left alone, not reset and pooled")
+	}
+}
+
+// multipartBenchBodies are small upload forms, as a profile or photo API
+// would receive.
+var (
+	multipartBenchBodies, multipartBenchBoundaries = makeMultipartBench(64)
+	multipartChecksum                              uint32
+)
+
+func makeMultipartBench(n int) ([][]byte, []string) {
+	bodies, boundaries := make([][]byte, n), make([]string, n)
+	for i := range bodies {
+		bodies[i], boundaries[i] = buildMultipart(uploadParts(i))
+	}
+	return bodies, boundaries
+}
+
+func benchmarkMultipart(b *testing.B, parse func([]byte, string, func(*formPart)) error) {
+	var sum uint32
+	handle := func(p *formPart) { sum = crc32.Update(sum, crc32.IEEETable, p.Body) }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		j := i % len(multipartBenchBodies)
+		if err := parse(multipartBenchBodies[j], multipartBenchBoundaries[j], handle); err != nil {
+			b.Fatal(err)
+		}
+	}
+	multipartChecksum = sum
+}
+
+func BenchmarkMultipartReadAll(b *testing.B) { benchmarkMultipart(b, parseMultipartFresh) }
+
+func BenchmarkMultipartPooledParts(b *testing.B) { benchmarkMultipart(b, parseMultipartPooled) }
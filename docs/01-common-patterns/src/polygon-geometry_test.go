@@ -0,0 +1,235 @@
+package perf
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// polygonSet holds many polygons as one interleaved lon/lat array, the way
+// they arrive from a decoder: polygon i is coords[offsets[i]:offsets[i+1]].
+type polygonSet struct {
+	coords  []float64
+	offsets []int
+}
+
+func (s *polygonSet) Len() int { return len(s.offsets) - 1 }
+
+func (s *polygonSet) Polygon(i int) []float64 {
+	return s.coords[s.offsets[i]:s.offsets[i+1]]
+}
+
+// projectX and projectY convert degrees to approximate meters around a
+// reference latitude (equirectangular projection).
+const (
+	earthRadius = 6_371_000.0
+	refLatRad   = 45 * math.Pi / 180
+)
+
+func projectX(lon float64) float64 { return lon * math.Pi / 180 * earthRadius * math.Cos(refLatRad) }
+func projectY(lat float64) float64 { return lat * math.Pi / 180 * earthRadius }
+
+// --- Reference: allocate a []geoPoint per polygon ---
+
+type geoPoint struct{ X, Y float64 }
+
+func projectPolygonAlloc(lonlat []float64) []geoPoint {
+	pts := make([]geoPoint, len(lonlat)/2)
+	for i := range pts {
+		pts[i] = geoPoint{projectX(lonlat[2*i]), projectY(lonlat[2*i+1])}
+	}
+	return pts
+}
+
+func polygonAreaPoints(pts []geoPoint) float64 {
+	var sum float64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		sum += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return math.Abs(sum) / 2
+}
+
+func containsPoints(pts []geoPoint, p geoPoint) bool {
+	inside := false
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		if (pts[i].Y > p.Y) != (pts[j].Y > p.Y) &&
+			p.X < (pts[j].X-pts[i].X)*(p.Y-pts[i].Y)/(pts[j].Y-pts[i].Y)+pts[i].X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// --- Reused flat x/y buffers ---
+
+// polygonScratch holds one projected polygon as two parallel arrays. The
+// arrays are reused for every polygon, growing only to the largest one.
+type polygonScratch struct {
+	xs, ys []float64
+}
+
+func (s *polygonScratch) project(lonlat []float64) {
+	n := len(lonlat) / 2
+	s.xs, s.ys = s.xs[:0], s.ys[:0]
+	for i := 0; i < n; i++ {
+		s.xs = append(s.xs, projectX(lonlat[2*i]))
+		s.ys = append(s.ys, projectY(lonlat[2*i+1]))
+	}
+}
+
+func (s *polygonScratch) area() float64 {
+	xs, ys := s.xs, s.ys
+	n := len(xs)
+	if n < 3 {
+		return 0
+	}
+	sum := xs[n-1]*ys[0] - xs[0]*ys[n-1]
+	for i := 0; i < n-1; i++ {
+		sum += xs[i]*ys[i+1] - xs[i+1]*ys[i]
+	}
+	return math.Abs(sum) / 2
+}
+
+func (s *polygonScratch) contains(x, y float64) bool {
+	xs, ys := s.xs, s.ys
+	inside := false
+	for i, j := 0, len(xs)-1; i < len(xs); j, i = i, i+1 {
+		if (ys[i] > y) != (ys[j] > y) && x < (xs[j]-xs[i])*(y-ys[i])/(ys[j]-ys[i])+xs[i] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// randomPolygons generates star-shaped polygons with vertices at sorted
+// angles around random centers, so every polygon is simple.
+func randomPolygons(count, vertices int, seed uint64) *polygonSet {
+	rng := rand.New(rand.NewPCG(seed, seed+7))
+	s := &polygonSet{offsets: []int{0}}
+	for p := 0; p < count; p++ {
+		cx, cy := rng.Float64()*10, 40+rng.Float64()*10
+		for v := 0; v < vertices; v++ {
+			angle := 2 * math.Pi * (float64(v) + rng.Float64()*0.8) / float64(vertices)
+			r := 0.01 + rng.Float64()*0.02
+			s.coords = append(s.coords, cx+r*math.Cos(angle), cy+r*math.Sin(angle))
+		}
+		s.offsets = append(s.offsets, len(s.coords))
+	}
+	return s
+}
+
+// polygonQuery returns a point near the middle of the polygon's bounding box.
+func polygonQuery(lonlat []float64) (float64, float64) {
+	var x, y float64
+	n := len(lonlat) / 2
+	for i := 0; i < n; i++ {
+		x += lonlat[2*i]
+		y += lonlat[2*i+1]
+	}
+	return projectX(x / float64(n)), projectY(y / float64(n))
+}
+
+func TestPolygonKnownShapes(t *testing.T) {
+	var s polygonScratch
+	s.xs, s.ys = []float64{0, 4, 4, 0}, []float64{0, 0, 3, 3}
+	if got := s.area(); got != 12 {
+		t.Errorf("rectangle area = %v, want 12", got)
+	}
+	if !s.contains(2, 1.5) || s.contains(5, 1) || s.contains(-0.1, 1) {
+		t.Error("rectangle containment is wrong")
+	}
+
+	s.xs, s.ys = []float64{0, 4, 0}, []float64{0, 0, 4}
+	if got := s.area(); got != 8 {
+		t.Errorf("triangle area = %v, want 8", got)
+	}
+	if !s.contains(1, 1) || s.contains(3, 3) {
+		t.Error("triangle containment is wrong")
+	}
+
+	// Clockwise winding must give the same (absolute) area.
+	s.xs, s.ys = []float64{0, 0, 4, 4}, []float64{0, 3, 3, 0}
+	if got := s.area(); got != 12 {
+		t.Errorf("clockwise rectangle area = %v, want 12", got)
+	}
+}
+
+func TestPolygonScratchMatchesReference(t *testing.T) {
+	set := randomPolygons(500, 24, 1)
+	var s polygonScratch
+	for i := 0; i < set.Len(); i++ {
+		poly := set.Polygon(i)
+		pts := projectPolygonAlloc(poly)
+		s.project(poly)
+
+		want := polygonAreaPoints(pts)
+		if got := s.area(); math.Abs(got-want) > 1e-9*want {
+			t.Fatalf("polygon %d: area %v, reference %v", i, got, want)
+		}
+
+		qx, qy := polygonQuery(poly)
+		for _, q := range []geoPoint{{qx, qy}, {qx + 5000, qy}, {qx, qy - 5000}, {qx + 900, qy + 900}} {
+			if got, want := s.contains(q.X, q.Y), containsPoints(pts, q); got != want {
+				t.Fatalf("polygon %d, point %v: contains %v, reference %v", i, q, got, want)
+			}
+		}
+		if !s.contains(qx, qy) {
+			t.Fatalf("polygon %d does not contain its own center", i)
+		}
+	}
+}
+
+func TestPolygonScratchDoesNotAllocate(t *testing.T) {
+	set := randomPolygons(10, 32, 2)
+	var s polygonScratch
+	s.project(set.Polygon(0))
+	i := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		s.project(set.Polygon(i % set.Len()))
+		_ = s.area()
+		i++
+	})
+	if allocs != 0 {
+		t.Fatalf("reused scratch allocated %v times", allocs)
+	}
+}
+
+var (
+	polygonBenchSet = randomPolygons(10_000, 32, 3)
+	polygonAreaSum  float64
+	polygonHits     int
+)
+
+func BenchmarkPolygonAllocPerCall(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		area, hits := 0.0, 0
+		for p := 0; p < polygonBenchSet.Len(); p++ {
+			poly := polygonBenchSet.Polygon(p)
+			pts := projectPolygonAlloc(poly)
+			area += polygonAreaPoints(pts)
+			qx, qy := polygonQuery(poly)
+			if containsPoints(pts, geoPoint{qx, qy}) {
+				hits++
+			}
+		}
+		polygonAreaSum, polygonHits = area, hits
+	}
+}
+
+func BenchmarkPolygonReusedSoA(b *testing.B) {
+	var s polygonScratch
+	for i := 0; i < b.N; i++ {
+		area, hits := 0.0, 0
+		for p := 0; p < polygonBenchSet.Len(); p++ {
+			poly := polygonBenchSet.Polygon(p)
+			s.project(poly)
+			area += s.area()
+			qx, qy := polygonQuery(poly)
+			if s.contains(qx, qy) {
+				hits++
+			}
+		}
+		polygonAreaSum, polygonHits = area, hits
+	}
+}
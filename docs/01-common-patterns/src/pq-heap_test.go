@@ -0,0 +1,226 @@
+package perf
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// PQItem is a scheduled task: lower Priority is served first.
+type PQItem struct {
+	Priority int64
+	ID       int64
+}
+
+// --- container/heap: items are boxed into interface values ---
+
+type boxedPQ []PQItem
+
+func (q boxedPQ) Len() int           { return len(q) }
+func (q boxedPQ) Less(i, j int) bool { return q[i].Priority < q[j].Priority }
+func (q boxedPQ) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+// Push and Pop exchange items with container/heap as any, so every PQItem
+// crossing the interface is copied to the heap.
+func (q *boxedPQ) Push(x any) { *q = append(*q, x.(PQItem)) }
+
+func (q *boxedPQ) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// --- Index-based heap over a preallocated slice ---
+
+// ItemHeap is a binary min-heap stored directly in a []PQItem. Once the
+// slice has reached its working capacity, Push and Pop never allocate.
+type ItemHeap struct {
+	items []PQItem
+}
+
+func NewItemHeap(capacity int) *ItemHeap {
+	return &ItemHeap{items: make([]PQItem, 0, capacity)}
+}
+
+func (h *ItemHeap) Len() int { return len(h.items) }
+
+func (h *ItemHeap) Push(it PQItem) {
+	h.items = append(h.items, it)
+	items := h.items
+	i := len(items) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if items[parent].Priority <= it.Priority {
+			break
+		}
+		items[i] = items[parent]
+		i = parent
+	}
+	items[i] = it
+}
+
+// Pop removes and returns the item with the lowest priority. The heap must
+// not be empty.
+func (h *ItemHeap) Pop() PQItem {
+	items := h.items
+	top := items[0]
+	n := len(items) - 1
+	last := items[n]
+	items = items[:n]
+	h.items = items
+	if n == 0 {
+		return top
+	}
+	// Move the hole at the root down, then drop the last item into it.
+	i := 0
+	for {
+		child := 2*i + 1
+		if child >= n {
+			break
+		}
+		if right := child + 1; right < n && items[right].Priority < items[child].Priority {
+			child = right
+		}
+		if last.Priority <= items[child].Priority {
+			break
+		}
+		items[i] = items[child]
+		i = child
+	}
+	items[i] = last
+	return top
+}
+
+// Reset empties the heap but keeps its backing array.
+func (h *ItemHeap) Reset() { h.items = h.items[:0] }
+
+func pqRandomItems(n int, seed uint64) []PQItem {
+	rng := rand.New(rand.NewPCG(seed, seed*3+1))
+	items := make([]PQItem, n)
+	for i := range items {
+		items[i] = PQItem{Priority: rng.Int64N(int64(n)), ID: int64(i)}
+	}
+	return items
+}
+
+func sortedPriorities(items []PQItem) []int64 {
+	p := make([]int64, len(items))
+	for i, it := range items {
+		p[i] = it.Priority
+	}
+	slices.Sort(p)
+	return p
+}
+
+func TestPriorityQueuesPopInOrder(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 10, 1000, 4097} {
+		items := pqRandomItems(n, uint64(n))
+		want := sortedPriorities(items)
+
+		h := NewItemHeap(0)
+		q := &boxedPQ{}
+		for _, it := range items {
+			h.Push(it)
+			heap.Push(q, it)
+		}
+		for i, p := range want {
+			if got := h.Pop(); got.Priority != p {
+				t.Fatalf("n=%d: ItemHeap pop %d has priority %d, want %d", n, i, got.Priority, p)
+			}
+			if got := heap.Pop(q).(PQItem); got.Priority != p {
+				t.Fatalf("n=%d: container/heap pop %d has priority %d, want %d", n, i, got.Priority, p)
+			}
+		}
+		if h.Len() != 0 || q.Len() != 0 {
+			t.Fatalf("n=%d: queues not empty after popping everything", n)
+		}
+	}
+}
+
+func TestItemHeapInterleavedOps(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+	h := NewItemHeap(16)
+	var ref []int64 // kept sorted
+	for i := 0; i < 20_000; i++ {
+		if len(ref) == 0 || rng.IntN(3) > 0 {
+			p := rng.Int64N(500)
+			h.Push(PQItem{Priority: p, ID: int64(i)})
+			ref = append(ref, p)
+			slices.Sort(ref)
+			continue
+		}
+		if got := h.Pop().Priority; got != ref[0] {
+			t.Fatalf("op %d: popped priority %d, want %d", i, got, ref[0])
+		}
+		ref = ref[1:]
+	}
+}
+
+func TestItemHeapReusesBackingArray(t *testing.T) {
+	items := pqRandomItems(10_000, 9)
+	h := NewItemHeap(len(items))
+	backing := &h.items[:1][0]
+	fillAndDrain := func() {
+		for _, it := range items {
+			h.Push(it)
+		}
+		for h.Len() > 0 {
+			h.Pop()
+		}
+		h.Reset()
+	}
+	for cycle := 0; cycle < 3; cycle++ {
+		fillAndDrain()
+		if &h.items[:1][0] != backing || cap(h.items) != len(items) {
+			t.Fatalf("cycle %d: heap replaced its backing array", cycle)
+		}
+	}
+	if allocs := testing.AllocsPerRun(5, fillAndDrain); allocs != 0 {
+		t.Fatalf("clear-and-refill cycle allocated %v times", allocs)
+	}
+}
+
+const pqBenchItems = 1_000_000
+
+var (
+	pqBenchInput = pqRandomItems(pqBenchItems, 42)
+	pqIDSink     int64
+)
+
+// Each operation pushes 1M items and pops them all. Both queues keep their
+// slice across operations, so the only allocations left are boxing.
+
+func BenchmarkPQContainerHeap(b *testing.B) {
+	q := make(boxedPQ, 0, pqBenchItems)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, it := range pqBenchInput {
+			heap.Push(&q, it)
+		}
+		var sum int64
+		for q.Len() > 0 {
+			sum += heap.Pop(&q).(PQItem).ID
+		}
+		pqIDSink = sum
+	}
+}
+
+func BenchmarkPQItemHeap(b *testing.B) {
+	h := NewItemHeap(pqBenchItems)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, it := range pqBenchInput {
+			h.Push(it)
+		}
+		var sum int64
+		for h.Len() > 0 {
+			sum += h.Pop().ID
+		}
+		pqIDSink = sum
+		h.Reset()
+	}
+}
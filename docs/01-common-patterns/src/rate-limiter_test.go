@@ -0,0 +1,197 @@
+package perf
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rateLimiter admits or rejects one event per call.
+type rateLimiter interface {
+	Allow() bool
+}
+
+var rateClockStart = time.Now()
+
+// monotonicNanos is the production clock: nanoseconds since process start.
+func monotonicNanos() int64 { return int64(time.Since(rateClockStart)) }
+
+// --- Mutex-guarded token bucket ---
+
+type MutexBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per nanosecond
+	burst  float64
+	tokens float64
+	last   int64
+	now    func() int64
+}
+
+func NewMutexBucket(perSecond float64, burst int, now func() int64) *MutexBucket {
+	return &MutexBucket{
+		rate:   perSecond / float64(time.Second),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+func (b *MutexBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.now()
+	b.tokens = min(b.burst, b.tokens+float64(t-b.last)*b.rate)
+	b.last = t
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// --- Lock-free bucket (GCRA) ---
+
+// AtomicBucket implements the generic cell rate algorithm, which is
+// equivalent to a token bucket but keeps all of its state in one int64: the
+// theoretical arrival time (TAT) of the next event. Each admitted event
+// pushes the TAT forward by one emission interval; an event is rejected if
+// that would put the TAT more than burst intervals ahead of now.
+type AtomicBucket struct {
+	tat      atomic.Int64
+	interval int64 // nanoseconds per token
+	limit    int64 // burst * interval
+	now      func() int64
+}
+
+func NewAtomicBucket(perSecond float64, burst int, now func() int64) *AtomicBucket {
+	interval := int64(float64(time.Second) / perSecond)
+	return &AtomicBucket{interval: interval, limit: int64(burst) * interval, now: now}
+}
+
+func (b *AtomicBucket) Allow() bool {
+	t := b.now()
+	for {
+		tat := b.tat.Load()
+		next := max(tat, t) + b.interval
+		if next-t > b.limit {
+			return false
+		}
+		if b.tat.CompareAndSwap(tat, next) {
+			return true
+		}
+	}
+}
+
+// fakeClock is a manually advanced clock safe for concurrent reads.
+type fakeClock struct {
+	t atomic.Int64
+}
+
+func (c *fakeClock) Now() int64 { return c.t.Load() }
+
+func (c *fakeClock) Advance(d time.Duration) { c.t.Add(int64(d)) }
+
+type limiterFactory func(perSecond float64, burst int, now func() int64) rateLimiter
+
+var limiterFactories = map[string]limiterFactory{
+	"mutex":  func(r float64, b int, now func() int64) rateLimiter { return NewMutexBucket(r, b, now) },
+	"atomic": func(r float64, b int, now func() int64) rateLimiter { return NewAtomicBucket(r, b, now) },
+}
+
+func TestRateLimiterNeverExceedsBurst(t *testing.T) {
+	const burst = 50
+	for name, newLimiter := range limiterFactories {
+		clock := &fakeClock{}
+		clock.Advance(time.Hour)
+		lim := newLimiter(100, burst, clock.Now)
+
+		// The clock doesn't move, so only the initial burst may be admitted,
+		// however many goroutines race for it.
+		var admitted atomic.Int64
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					if lim.Allow() {
+						admitted.Add(1)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		if got := admitted.Load(); got != burst {
+			t.Errorf("%s: admitted %d events at a single instant, want exactly %d", name, got, burst)
+		}
+	}
+}
+
+func TestRateLimiterSustainedRate(t *testing.T) {
+	const (
+		rate   = 200
+		burst  = 20
+		window = 10 * time.Second
+		step   = time.Millisecond
+	)
+	for name, newLimiter := range limiterFactories {
+		clock := &fakeClock{}
+		clock.Advance(time.Hour)
+		lim := newLimiter(rate, burst, clock.Now)
+
+		var admitted atomic.Int64
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						if lim.Allow() {
+							admitted.Add(1)
+						}
+					}
+				}
+			}()
+		}
+		for elapsed := time.Duration(0); elapsed < window; elapsed += step {
+			clock.Advance(step)
+			// Drain whatever the tick made available, so the total doesn't
+			// depend on how the callers were scheduled.
+			for lim.Allow() {
+				admitted.Add(1)
+			}
+		}
+		close(stop)
+		wg.Wait()
+
+		want := int64(rate*window.Seconds()) + burst
+		if got := admitted.Load(); got > want || got < want-2 {
+			t.Errorf("%s: admitted %d events over %v, want about %d and never more", name, got, window, want)
+		}
+	}
+}
+
+func BenchmarkRateLimiterMutex(b *testing.B) {
+	lim := NewMutexBucket(1e6, 1000, monotonicNanos)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lim.Allow()
+		}
+	})
+}
+
+func BenchmarkRateLimiterAtomic(b *testing.B) {
+	lim := NewAtomicBucket(1e6, 1000, monotonicNanos)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			lim.Allow()
+		}
+	})
+}
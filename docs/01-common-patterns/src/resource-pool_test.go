@@ -0,0 +1,308 @@
+package perf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var ErrPoolClosed = errors.New("pool: closed")
+
+// --- Pool with reused lease wrappers ---
+
+// Lease is a checked-out resource. Each Lease is created once together with
+// its resource and travels through the idle list with it, so Get and Put
+// never allocate.
+type Lease[T any] struct {
+	Value T
+}
+
+type ResourcePool[T any] struct {
+	idle    chan *Lease[T]
+	slots   chan struct{} // one token per resource that exists
+	done    chan struct{}
+	once    sync.Once
+	factory func() (T, error)
+}
+
+func NewResourcePool[T any](maxSize int, factory func() (T, error)) *ResourcePool[T] {
+	return &ResourcePool[T]{
+		idle:    make(chan *Lease[T], maxSize),
+		slots:   make(chan struct{}, maxSize),
+		done:    make(chan struct{}),
+		factory: factory,
+	}
+}
+
+// Get returns an idle resource, creates a new one if the pool is below its
+// maximum size, or blocks until one is returned, ctx is done, or the pool
+// is closed.
+func (p *ResourcePool[T]) Get(ctx context.Context) (*Lease[T], error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+	select {
+	case l := <-p.idle:
+		return l, nil
+	default:
+	}
+	select {
+	case l := <-p.idle:
+		return l, nil
+	case p.slots <- struct{}{}:
+		v, err := p.factory()
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		return &Lease[T]{Value: v}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+		return nil, ErrPoolClosed
+	}
+}
+
+// Put returns a lease to the pool. The caller must not use it afterwards.
+func (p *ResourcePool[T]) Put(l *Lease[T]) {
+	p.idle <- l // never blocks: at most maxSize leases exist
+}
+
+// Close makes every current and future Get fail with ErrPoolClosed.
+func (p *ResourcePool[T]) Close() {
+	p.once.Do(func() { close(p.done) })
+}
+
+// --- Naive pool: a fresh wrapper on every Get ---
+
+type naiveLease[T any] struct {
+	Value T
+	pool  *NaivePool[T]
+}
+
+type NaivePool[T any] struct {
+	idle    chan T
+	slots   chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	factory func() (T, error)
+}
+
+func NewNaivePool[T any](maxSize int, factory func() (T, error)) *NaivePool[T] {
+	return &NaivePool[T]{
+		idle:    make(chan T, maxSize),
+		slots:   make(chan struct{}, maxSize),
+		done:    make(chan struct{}),
+		factory: factory,
+	}
+}
+
+func (p *NaivePool[T]) Get(ctx context.Context) (*naiveLease[T], error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+	var v T
+	select {
+	case v = <-p.idle:
+		return &naiveLease[T]{Value: v, pool: p}, nil
+	default:
+	}
+	select {
+	case v = <-p.idle:
+	case p.slots <- struct{}{}:
+		var err error
+		if v, err = p.factory(); err != nil {
+			<-p.slots
+			return nil, err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.done:
+		return nil, ErrPoolClosed
+	}
+	return &naiveLease[T]{Value: v, pool: p}, nil
+}
+
+func (l *naiveLease[T]) Release() { l.pool.idle <- l.Value }
+
+func (p *NaivePool[T]) Close() {
+	p.once.Do(func() { close(p.done) })
+}
+
+// fakeConn stands in for a network connection.
+type fakeConn struct {
+	id   int64
+	uses int
+}
+
+func connFactory(created *atomic.Int64) func() (*fakeConn, error) {
+	return func() (*fakeConn, error) {
+		return &fakeConn{id: created.Add(1)}, nil
+	}
+}
+
+func TestResourcePoolReuses(t *testing.T) {
+	var created atomic.Int64
+	p := NewResourcePool(4, connFactory(&created))
+	ctx := context.Background()
+
+	first, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(first)
+	for i := 0; i < 100; i++ {
+		l, err := p.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != first {
+			t.Fatalf("Get %d returned a different lease with only one in use", i)
+		}
+		l.Value.uses++
+		p.Put(l)
+	}
+	if created.Load() != 1 || first.Value.uses != 100 {
+		t.Fatalf("created %d resources, first used %d times; want 1 and 100", created.Load(), first.Value.uses)
+	}
+}
+
+func TestResourcePoolNeverExceedsMax(t *testing.T) {
+	const maxSize = 4
+	var created atomic.Int64
+	p := NewResourcePool(maxSize, connFactory(&created))
+
+	var inUse, peak atomic.Int64
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				l, err := p.Get(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n := inUse.Add(1)
+				for {
+					old := peak.Load()
+					if n <= old || peak.CompareAndSwap(old, n) {
+						break
+					}
+				}
+				l.Value.uses++ // exclusive access; -race would flag sharing
+				inUse.Add(-1)
+				p.Put(l)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > maxSize || created.Load() > maxSize {
+		t.Fatalf("peak in use %d, created %d; max is %d", peak.Load(), created.Load(), maxSize)
+	}
+}
+
+func TestResourcePoolBlocksAtMax(t *testing.T) {
+	var created atomic.Int64
+	p := NewResourcePool(1, connFactory(&created))
+	held, _ := p.Get(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get on exhausted pool: got %v, want DeadlineExceeded", err)
+	}
+
+	got := make(chan *Lease[*fakeConn])
+	go func() {
+		l, _ := p.Get(context.Background())
+		got <- l
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Put(held)
+	if l := <-got; l != held {
+		t.Fatal("blocked Get did not receive the returned lease")
+	}
+}
+
+func TestResourcePoolGetAfterClose(t *testing.T) {
+	var created atomic.Int64
+	p := NewResourcePool(1, connFactory(&created))
+	held, _ := p.Get(context.Background())
+
+	blocked := make(chan error)
+	go func() {
+		_, err := p.Get(context.Background())
+		blocked <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	if err := <-blocked; !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("blocked Get after Close: got %v, want ErrPoolClosed", err)
+	}
+
+	p.Put(held)
+	if _, err := p.Get(context.Background()); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("Get after Close: got %v, want ErrPoolClosed", err)
+	}
+	p.Close() // idempotent
+}
+
+func TestResourcePoolGetPutDoesNotAllocate(t *testing.T) {
+	var created atomic.Int64
+	p := NewResourcePool(2, connFactory(&created))
+	ctx := context.Background()
+	l, _ := p.Get(ctx)
+	p.Put(l)
+	allocs := testing.AllocsPerRun(1000, func() {
+		l, _ := p.Get(ctx)
+		p.Put(l)
+	})
+	if allocs != 0 {
+		t.Fatalf("Get/Put allocated %v times", allocs)
+	}
+}
+
+const benchPoolMax = 8
+
+func BenchmarkResourcePoolNaive(b *testing.B) {
+	var created atomic.Int64
+	p := NewNaivePool(benchPoolMax, connFactory(&created))
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l, err := p.Get(ctx)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			l.Release()
+		}
+	})
+}
+
+func BenchmarkResourcePoolReusedLease(b *testing.B) {
+	var created atomic.Int64
+	p := NewResourcePool(benchPoolMax, connFactory(&created))
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l, err := p.Get(ctx)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			p.Put(l)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package perf
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type responseItem struct {
+	Name  string
+	Price int64
+}
+
+var responseItems = func() []responseItem {
+	items := make([]responseItem, 50)
+	for i := range items {
+		items[i] = responseItem{Name: "product-" + strconv.Itoa(i), Price: int64(i*137 + 99)}
+	}
+	return items
+}()
+
+// renderBody writes the response body into buf using only append-style calls.
+func renderBody(buf *bytes.Buffer, items []responseItem) {
+	buf.WriteString("<html><body><ul>\n")
+	var num [20]byte
+	for _, it := range items {
+		buf.WriteString("<li>")
+		buf.WriteString(it.Name)
+		buf.WriteString(": $")
+		buf.Write(strconv.AppendInt(num[:0], it.Price, 10))
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</ul></body></html>\n")
+}
+
+// renderFresh builds the body in a new buffer for every request.
+func renderFresh(w io.Writer, items []responseItem) error {
+	var buf bytes.Buffer
+	renderBody(&buf, items)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// maxPooledResponseBuffer keeps one unusually large response from pinning a
+// large buffer in the pool forever.
+const maxPooledResponseBuffer = 64 << 10
+
+var responseBufferPool = sync.Pool{
+	New: func() any {
+		return bytes.NewBuffer(make([]byte, 0, 4096))
+	},
+}
+
+func acquireResponseBuffer() *bytes.Buffer {
+	return responseBufferPool.Get().(*bytes.Buffer)
+}
+
+func releaseResponseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledResponseBuffer {
+		return
+	}
+	buf.Reset()
+	responseBufferPool.Put(buf)
+}
+
+// renderPooled builds the body in a pooled buffer, flushes it to w, and
+// returns the buffer to the pool.
+func renderPooled(w io.Writer, items []responseItem) error {
+	buf := acquireResponseBuffer()
+	defer releaseResponseBuffer(buf)
+	renderBody(buf, items)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// discardResponse stands in for an http.ResponseWriter.
+type discardResponse struct {
+	written int
+}
+
+func (d *discardResponse) Write(p []byte) (int, error) {
+	d.written += len(p)
+	return len(p), nil
+}
+
+func TestRenderPooledMatchesFresh(t *testing.T) {
+	var fresh, pooled bytes.Buffer
+	if err := renderFresh(&fresh, responseItems); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		pooled.Reset()
+		if err := renderPooled(&pooled, responseItems); err != nil {
+			t.Fatal(err)
+		}
+		if pooled.String() != fresh.String() {
+			t.Fatalf("render %d: pooled output differs from fresh output", i)
+		}
+	}
+	if !bytes.Contains(fresh.Bytes(), []byte("<li>product-3: $510</li>")) {
+		t.Errorf("unexpected body:\n%s", fresh.String())
+	}
+}
+
+func TestResponseBufferResetOnRelease(t *testing.T) {
+	buf := acquireResponseBuffer()
+	buf.WriteString("secret from a previous request")
+	releaseResponseBuffer(buf)
+	if buf.Len() != 0 {
+		t.Fatalf("released buffer still holds %q", buf.String())
+	}
+
+	for i := 0; i < 100; i++ {
+		b := acquireResponseBuffer()
+		if b.Len() != 0 {
+			t.Fatalf("acquired buffer is not empty: %q", b.String())
+		}
+		b.WriteString("dirty")
+		releaseResponseBuffer(b)
+	}
+}
+
+func TestResponseBufferReturnedToPool(t *testing.T) {
+	w := &discardResponse{}
+	allocs := testing.AllocsPerRun(1000, func() {
+		_ = renderPooled(w, responseItems)
+	})
+	// Under -race, sync.Pool drops a quarter of Puts at random, so some runs
+	// refill the pool. AllocsPerRun averages and truncates to an integer, and
+	// a refill costs fewer than four allocations, so this still rounds to 0.
+	if allocs != 0 {
+		t.Fatalf("renderPooled allocated %v times per call; buffers are not being reused", allocs)
+	}
+}
+
+func TestOversizedResponseBufferDropped(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, 2*maxPooledResponseBuffer))
+	buf.WriteString("large")
+	releaseResponseBuffer(buf)
+	if buf.Len() == 0 {
+		t.Fatal("oversized buffer should be left alone, not reset and pooled")
+	}
+}
+
+func BenchmarkResponseFreshBuffer(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := &discardResponse{}
+		for pb.Next() {
+			_ = renderFresh(w, responseItems)
+		}
+	})
+}
+
+func BenchmarkResponsePooledBuffer(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := &discardResponse{}
+		for pb.Next() {
+			_ = renderPooled(w, responseItems)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package perf
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+// Runs are encoded as (count, value) byte pairs with count in 1..255.
+// Longer runs are split into several pairs.
+const maxRLERun = 255
+
+var errRLECorrupt = errors.New("rle: odd length or zero-length run")
+
+// AppendRLE appends the run-length encoding of src to dst and returns the
+// extended slice. It allocates only if dst lacks room.
+func AppendRLE(dst, src []byte) []byte {
+	for i := 0; i < len(src); {
+		v := src[i]
+		n := 1
+		for n < maxRLERun && i+n < len(src) && src[i+n] == v {
+			n++
+		}
+		dst = append(dst, byte(n), v)
+		i += n
+	}
+	return dst
+}
+
+// AppendRLEDecode appends the bytes encoded in enc to dst. On corrupt input
+// it returns dst at its original length, so a caller reusing the buffer
+// never sees a partly decoded result.
+func AppendRLEDecode(dst, enc []byte) ([]byte, error) {
+	if len(enc)%2 != 0 {
+		return dst, errRLECorrupt
+	}
+	start := len(dst)
+	for i := 0; i < len(enc); i += 2 {
+		n, v := int(enc[i]), enc[i+1]
+		if n == 0 {
+			return dst[:start], errRLECorrupt
+		}
+		for ; n > 0; n-- {
+			dst = append(dst, v)
+		}
+	}
+	return dst, nil
+}
+
+// encodeRLEAlloc and decodeRLEAlloc return a new, growing slice per call,
+// the way encoders without a dst parameter are usually written.
+func encodeRLEAlloc(src []byte) []byte {
+	var out []byte
+	for i := 0; i < len(src); {
+		v := src[i]
+		n := 1
+		for n < maxRLERun && i+n < len(src) && src[i+n] == v {
+			n++
+		}
+		out = append(out, byte(n), v)
+		i += n
+	}
+	return out
+}
+
+func decodeRLEAlloc(enc []byte) ([]byte, error) {
+	if len(enc)%2 != 0 {
+		return nil, errRLECorrupt
+	}
+	var out []byte
+	for i := 0; i < len(enc); i += 2 {
+		n, v := int(enc[i]), enc[i+1]
+		if n == 0 {
+			return nil, errRLECorrupt
+		}
+		for ; n > 0; n-- {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+var rleEdgeCases = map[string][]byte{
+	"empty":       {},
+	"single byte": {7},
+	"max run":     bytes.Repeat([]byte{'a'}, maxRLERun),
+	"max run + 1": bytes.Repeat([]byte{'a'}, maxRLERun+1),
+	"long run":    bytes.Repeat([]byte{0}, 10*maxRLERun+3),
+	"alternating": bytes.Repeat([]byte{1, 2}, 500),
+	"all byte values": func() []byte {
+		b := make([]byte, 256)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return b
+	}(),
+	"runs of mixed length": append(append(bytes.Repeat([]byte{'x'}, 300), 'y'),
+		bytes.Repeat([]byte{'z'}, 2)...),
+}
+
+func TestRLERoundTrip(t *testing.T) {
+	for name, src := range rleEdgeCases {
+		enc := AppendRLE(nil, src)
+		if alloc := encodeRLEAlloc(src); !bytes.Equal(enc, alloc) {
+			t.Fatalf("%s: AppendRLE = %v, encodeRLEAlloc = %v", name, enc, alloc)
+		}
+		got, err := AppendRLEDecode(nil, enc)
+		if err != nil || !bytes.Equal(got, src) {
+			t.Fatalf("%s: decoded %d bytes (err %v), want %d", name, len(got), err, len(src))
+		}
+		if got, err := decodeRLEAlloc(enc); err != nil || !bytes.Equal(got, src) {
+			t.Fatalf("%s: decodeRLEAlloc gave %d bytes (err %v), want %d", name, len(got), err, len(src))
+		}
+	}
+}
+
+func TestRLEEncoding(t *testing.T) {
+	if got, want := AppendRLE(nil, []byte("aaabcc")), []byte{3, 'a', 1, 'b', 2, 'c'}; !bytes.Equal(got, want) {
+		t.Fatalf("AppendRLE(aaabcc) = %v, want %v", got, want)
+	}
+	// A run one byte longer than the maximum splits into two pairs.
+	if got, want := AppendRLE(nil, rleEdgeCases["max run + 1"]), []byte{255, 'a', 1, 'a'}; !bytes.Equal(got, want) {
+		t.Fatalf("256-byte run encoded as %v, want %v", got, want)
+	}
+	if got := AppendRLE(nil, rleEdgeCases["alternating"]); len(got) != 2000 {
+		t.Fatalf("alternating input encoded to %d bytes, want 2000 (worst case doubles)", len(got))
+	}
+}
+
+func TestRLEAppendKeepsPrefix(t *testing.T) {
+	src := []byte("hello")
+	enc := AppendRLE([]byte("hdr:"), src)
+	if !bytes.HasPrefix(enc, []byte("hdr:")) {
+		t.Fatalf("AppendRLE overwrote dst: %q", enc)
+	}
+	dec, err := AppendRLEDecode([]byte("out:"), enc[4:])
+	if err != nil || string(dec) != "out:hello" {
+		t.Fatalf("AppendRLEDecode = %q, %v", dec, err)
+	}
+}
+
+func TestRLEDecodeRejectsCorruptInput(t *testing.T) {
+	for _, enc := range [][]byte{{3}, {0, 'a'}, {2, 'a', 0, 'b'}, {200, 'a', 3, 'b', 0, 'c'}} {
+		dst, err := AppendRLEDecode([]byte("hdr:"), enc)
+		if err != errRLECorrupt {
+			t.Errorf("AppendRLEDecode(%v) error = %v, want errRLECorrupt", enc, err)
+		}
+		// Runs decoded before the corrupt pair must not be left behind.
+		if string(dst) != "hdr:" {
+			t.Errorf("AppendRLEDecode(%v) returned %d bytes, want dst unchanged at 4", enc, len(dst))
+		}
+	}
+}
+
+func TestRLEReusedBuffersDoNotAllocate(t *testing.T) {
+	src := rleBenchInputs[0]
+	enc := AppendRLE(nil, src)
+	dec, _ := AppendRLEDecode(nil, enc)
+	allocs := testing.AllocsPerRun(100, func() {
+		enc = AppendRLE(enc[:0], src)
+		dec, _ = AppendRLEDecode(dec[:0], enc)
+	})
+	if allocs != 0 {
+		t.Fatalf("round trip into reused buffers allocated %v times", allocs)
+	}
+}
+
+// rleBenchInputs look like rows of a sparse raster or a bitmap index:
+// long runs of background with short bursts of noise.
+var (
+	rleBenchInputs = makeRLEInputs(1000, 2048)
+	rleLenSink     int
+)
+
+func makeRLEInputs(count, size int) [][]byte {
+	rng := rand.New(rand.NewPCG(11, 13))
+	out := make([][]byte, count)
+	for i := range out {
+		row := make([]byte, 0, size)
+		for len(row) < size {
+			run := 1 + rng.IntN(64)
+			v := byte(0)
+			if rng.IntN(4) == 0 {
+				run, v = 1+rng.IntN(4), byte(rng.IntN(256))
+			}
+			for j := 0; j < run && len(row) < size; j++ {
+				row = append(row, v)
+			}
+		}
+		out[i] = row
+	}
+	return out
+}
+
+// Each operation encodes and decodes all 1,000 inputs.
+
+func BenchmarkRLEAllocPerCall(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, src := range rleBenchInputs {
+			dec, err := decodeRLEAlloc(encodeRLEAlloc(src))
+			if err != nil {
+				b.Fatal(err)
+			}
+			n += len(dec)
+		}
+		rleLenSink = n
+	}
+}
+
+func BenchmarkRLEAppendReused(b *testing.B) {
+	var enc, dec []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, src := range rleBenchInputs {
+			var err error
+			enc = AppendRLE(enc[:0], src)
+			dec, err = AppendRLEDecode(dec[:0], enc)
+			if err != nil {
+				b.Fatal(err)
+			}
+			n += len(dec)
+		}
+		rleLenSink = n
+	}
+}
@@ -0,0 +1,169 @@
+package perf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"hash/adler32"
+	"io"
+	"math/rand/v2"
+	"testing"
+)
+
+const adlerMod = 65521
+
+// RollingHash is an Adler-32 checksum over a fixed-size window that can be
+// slid forward one byte at a time in O(1), as in rsync's weak checksum.
+type RollingHash struct {
+	a, b uint32
+	n    uint32 // window size modulo adlerMod
+}
+
+// NewRollingHash initializes the checksum over the first window.
+func NewRollingHash(window []byte) *RollingHash {
+	h := &RollingHash{a: 1, n: uint32(len(window)) % adlerMod}
+	for _, c := range window {
+		h.a = (h.a + uint32(c)) % adlerMod
+		h.b = (h.b + h.a) % adlerMod
+	}
+	return h
+}
+
+// Roll slides the window by one byte: in enters at the end and out, the
+// oldest byte, leaves from the front.
+func (h *RollingHash) Roll(in, out byte) {
+	h.a = (h.a + adlerMod - uint32(out) + uint32(in)) % adlerMod
+	nOut := h.n * uint32(out) % adlerMod
+	h.b = (h.b + adlerMod - nOut + h.a + adlerMod - 1) % adlerMod
+}
+
+func (h *RollingHash) Sum32() uint32 {
+	return h.b<<16 | h.a
+}
+
+var errRollingWindow = errors.New("rolling hash: window must be at least 1 byte")
+
+// rollingScan streams r through a fixed ring buffer holding the current
+// window and calls fn with the checksum of every full window.
+func rollingScan(r io.Reader, window int, fn func(uint32)) error {
+	if window < 1 {
+		return errRollingWindow
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	ring := make([]byte, window)
+	if _, err := io.ReadFull(br, ring); err != nil {
+		return err
+	}
+	h := NewRollingHash(ring)
+	fn(h.Sum32())
+	for pos := 0; ; pos = (pos + 1) % window {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		h.Roll(c, ring[pos])
+		ring[pos] = c
+		fn(h.Sum32())
+	}
+}
+
+// recomputeScan checksums every window from scratch.
+func recomputeScan(data []byte, window int, fn func(uint32)) {
+	for i := 0; i+window <= len(data); i++ {
+		fn(adler32.Checksum(data[i : i+window]))
+	}
+}
+
+func rollingInput(n int) []byte {
+	rng := rand.New(rand.NewPCG(5, 8))
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(rng.Uint32())
+	}
+	return data
+}
+
+func TestRollingHashMatchesFullRecompute(t *testing.T) {
+	data := rollingInput(20_000)
+	for _, window := range []int{1, 16, 31, 512, 5552, 10_000} {
+		h := NewRollingHash(data[:window])
+		for i := window; ; i++ {
+			start := i - window
+			if got, want := h.Sum32(), adler32.Checksum(data[start:i]); got != want {
+				t.Fatalf("window %d at offset %d: rolling %08x, full %08x", window, start, got, want)
+			}
+			if i == len(data) {
+				break
+			}
+			h.Roll(data[i], data[start])
+		}
+	}
+}
+
+func TestRollingHashAllBytesHigh(t *testing.T) {
+	data := bytes.Repeat([]byte{0xff}, 70_000)
+	const window = 6000
+	h := NewRollingHash(data[:window])
+	for i := window; i < len(data); i++ {
+		h.Roll(data[i], data[i-window])
+	}
+	if got, want := h.Sum32(), adler32.Checksum(data[len(data)-window:]); got != want {
+		t.Fatalf("rolling %08x, full %08x", got, want)
+	}
+}
+
+func TestRollingScanMatchesRecompute(t *testing.T) {
+	data := rollingInput(50_000)
+	const window = 64
+	var want, got []uint32
+	recomputeScan(data, window, func(s uint32) { want = append(want, s) })
+	if err := rollingScan(bytes.NewReader(data), window, func(s uint32) { got = append(got, s) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sums, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sum %d: %08x, want %08x", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRollingScanRejectsEmptyWindow(t *testing.T) {
+	for _, window := range []int{0, -1} {
+		calls := 0
+		err := rollingScan(bytes.NewReader(rollingInput(100)), window, func(uint32) { calls++ })
+		if !errors.Is(err, errRollingWindow) || calls != 0 {
+			t.Fatalf("window %d: err = %v after %d sums, want errRollingWindow", window, err, calls)
+		}
+	}
+}
+
+const rollingWindow = 64
+
+var (
+	rollingData = rollingInput(1 << 20)
+	rollingSink uint32
+)
+
+func BenchmarkChecksumRecompute(b *testing.B) {
+	b.SetBytes(int64(len(rollingData)))
+	for i := 0; i < b.N; i++ {
+		var x uint32
+		recomputeScan(rollingData, rollingWindow, func(s uint32) { x ^= s })
+		rollingSink = x
+	}
+}
+
+func BenchmarkChecksumRolling(b *testing.B) {
+	b.SetBytes(int64(len(rollingData)))
+	for i := 0; i < b.N; i++ {
+		var x uint32
+		_ = rollingScan(bytes.NewReader(rollingData), rollingWindow, func(s uint32) { x ^= s })
+		rollingSink = x
+	}
+}
@@ -0,0 +1,224 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// windowAggregator counts events over the last window seconds, where the
+// window ending at now covers seconds (now-window, now]. Events may arrive
+// out of order; one that is already outside the window of the newest second
+// seen, or has a negative second, is dropped.
+type windowAggregator interface {
+	Record(sec, n int64)
+	Sum(now int64) int64
+}
+
+// --- Map keyed by second, pruned on every update ---
+
+type MapWindow struct {
+	window  int64
+	latest  int64 // newest second recorded
+	buckets map[int64]int64
+}
+
+func NewMapWindow(window int) *MapWindow {
+	return &MapWindow{window: int64(window), latest: -1, buckets: make(map[int64]int64)}
+}
+
+func (w *MapWindow) Record(sec, n int64) {
+	if sec < 0 || sec <= w.latest-w.window {
+		return
+	}
+	w.latest = max(w.latest, sec)
+	w.buckets[sec] += n
+	for s := range w.buckets {
+		if s <= w.latest-w.window {
+			delete(w.buckets, s)
+		}
+	}
+}
+
+func (w *MapWindow) Sum(now int64) int64 {
+	var total int64
+	for s, n := range w.buckets {
+		if s > now-w.window && s <= now {
+			total += n
+		}
+	}
+	return total
+}
+
+// --- Preallocated ring of per-second buckets ---
+
+type windowBucket struct {
+	sec   int64
+	count int64
+}
+
+// RingWindow keeps one bucket per second of the window. Second s lives in
+// slot s % window; a slot holding an older second is stale and is reset
+// when reused, so expiry costs nothing.
+type RingWindow struct {
+	buckets []windowBucket
+	latest  int64 // newest second recorded
+}
+
+func NewRingWindow(window int) *RingWindow {
+	w := &RingWindow{buckets: make([]windowBucket, window), latest: -1}
+	for i := range w.buckets {
+		w.buckets[i].sec = -1
+	}
+	return w
+}
+
+func (w *RingWindow) Record(sec, n int64) {
+	// A late event older than the window would land in a slot that now
+	// holds a newer second and wipe its count. Within the window, the slot
+	// holds sec itself or a second that has expired.
+	if sec < 0 || sec <= w.latest-int64(len(w.buckets)) {
+		return
+	}
+	w.latest = max(w.latest, sec)
+	b := &w.buckets[sec%int64(len(w.buckets))]
+	if b.sec != sec {
+		b.sec, b.count = sec, 0
+	}
+	b.count += n
+}
+
+func (w *RingWindow) Sum(now int64) int64 {
+	oldest := now - int64(len(w.buckets))
+	var total int64
+	for _, b := range w.buckets {
+		if b.sec > oldest && b.sec <= now {
+			total += b.count
+		}
+	}
+	return total
+}
+
+const slidingWindowSecs = 60
+
+func TestSlidingWindowAggregate(t *testing.T) {
+	for name, w := range map[string]windowAggregator{
+		"map":  NewMapWindow(slidingWindowSecs),
+		"ring": NewRingWindow(slidingWindowSecs),
+	} {
+		for sec := int64(0); sec < 100; sec++ {
+			w.Record(sec, sec)
+		}
+		// Seconds 40..99 are inside the window ending at 99.
+		if got, want := w.Sum(99), int64((40+99)*60/2); got != want {
+			t.Errorf("%s: Sum(99) = %d, want %d", name, got, want)
+		}
+		// Ten seconds later, seconds 50..99 remain.
+		if got, want := w.Sum(109), int64((50+99)*50/2); got != want {
+			t.Errorf("%s: Sum(109) = %d, want %d", name, got, want)
+		}
+		// A second that reuses an expired slot starts from zero.
+		w.Record(160, 7)
+		if got := w.Sum(160); got != 7 {
+			t.Errorf("%s: Sum(160) = %d, want 7", name, got)
+		}
+		if got := w.Sum(1000); got != 0 {
+			t.Errorf("%s: Sum(1000) = %d, want 0 once everything expired", name, got)
+		}
+	}
+}
+
+func TestSlidingWindowRingMatchesMap(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	m, r := NewMapWindow(slidingWindowSecs), NewRingWindow(slidingWindowSecs)
+	sec := int64(0)
+	for i := 0; i < 50_000; i++ {
+		switch rng.IntN(100) {
+		case 0:
+			sec += int64(rng.IntN(90)) // occasional gaps longer than the window
+		case 1, 2, 3, 4, 5:
+			sec++
+		}
+		n := int64(rng.IntN(10))
+		m.Record(sec, n)
+		r.Record(sec, n)
+		if got, want := r.Sum(sec), m.Sum(sec); got != want {
+			t.Fatalf("event %d at second %d: ring sum %d, map sum %d", i, sec, got, want)
+		}
+	}
+}
+
+func TestSlidingWindowOutOfOrder(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	m, r := NewMapWindow(slidingWindowSecs), NewRingWindow(slidingWindowSecs)
+	latest := int64(0)
+	for i := 0; i < 50_000; i++ {
+		if rng.IntN(50) == 0 {
+			latest += int64(rng.IntN(10))
+		}
+		// Most events are late, some by more than the window, and a few
+		// carry a negative second.
+		sec := latest - int64(rng.IntN(2*slidingWindowSecs))
+		if rng.IntN(1000) == 0 {
+			sec = -1 - int64(rng.IntN(100))
+		}
+		n := int64(1 + rng.IntN(10))
+		m.Record(sec, n)
+		r.Record(sec, n)
+		for _, now := range []int64{latest, latest + 1, latest + slidingWindowSecs/2} {
+			if got, want := r.Sum(now), m.Sum(now); got != want {
+				t.Fatalf("event %d (second %d, newest %d): ring Sum(%d) = %d, map %d", i, sec, latest, now, got, want)
+			}
+		}
+	}
+
+	// A late event inside the window is counted; one beyond it is dropped
+	// instead of wiping the newer second that shares its slot.
+	w := NewRingWindow(slidingWindowSecs)
+	w.Record(100, 5)
+	w.Record(99, 2)
+	w.Record(100-slidingWindowSecs, 1000)
+	if got := w.Sum(100); got != 7 {
+		t.Fatalf("Sum(100) = %d, want 7", got)
+	}
+}
+
+func TestRingWindowDoesNotAllocate(t *testing.T) {
+	w := NewRingWindow(slidingWindowSecs)
+	sec := int64(0)
+	allocs := testing.AllocsPerRun(1000, func() {
+		sec++
+		w.Record(sec, 1)
+		_ = w.Sum(sec)
+	})
+	if allocs != 0 {
+		t.Fatalf("ring window allocated %v times", allocs)
+	}
+}
+
+// Benchmarks simulate 10,000 events per second, with the aggregate queried
+// on every 1,000th event, as a metrics endpoint or an adaptive limiter would.
+const (
+	windowEventsPerSec = 10_000
+	windowQueryEvery   = 1_000
+)
+
+var windowSumSink int64
+
+func benchmarkWindow(b *testing.B, w windowAggregator) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sec := int64(i / windowEventsPerSec)
+		w.Record(sec, 1)
+		if i%windowQueryEvery == 0 {
+			windowSumSink = w.Sum(sec)
+		}
+	}
+}
+
+func BenchmarkSlidingWindowMap(b *testing.B) {
+	benchmarkWindow(b, NewMapWindow(slidingWindowSecs))
+}
+
+func BenchmarkSlidingWindowRing(b *testing.B) {
+	benchmarkWindow(b, NewRingWindow(slidingWindowSecs))
+}
@@ -0,0 +1,248 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// When one list is this many times longer than the other, the intersection
+// gallops through the long list instead of merging.
+const gallopRatio = 32
+
+// AppendIntersection appends the values present in both a and b to dst, in
+// ascending order. Both inputs must be sorted and free of duplicates, as
+// posting lists of document IDs are.
+func AppendIntersection(dst, a, b []uint32) []uint32 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(a)*gallopRatio < len(b) {
+		return appendGallop(dst, a, b)
+	}
+	return appendMerge(dst, a, b)
+}
+
+// appendMerge walks both lists in step: O(len(a) + len(b)).
+func appendMerge(dst, a, b []uint32) []uint32 {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			dst = append(dst, a[i])
+			i++
+			j++
+		}
+	}
+	return dst
+}
+
+// appendGallop looks up each value of the short list in the long one by
+// doubling a step from the previous match and then binary searching the
+// last step: O(len(small) * log(len(large)/len(small))).
+func appendGallop(dst, small, large []uint32) []uint32 {
+	lo := 0
+	for _, v := range small {
+		step := 1
+		hi := lo
+		for hi < len(large) && large[hi] < v {
+			lo = hi + 1
+			hi += step
+			step *= 2
+		}
+		hi = min(hi, len(large)-1)
+		if lo > hi {
+			break
+		}
+		k, found := slices.BinarySearch(large[lo:hi+1], v)
+		lo += k
+		if found {
+			dst = append(dst, v)
+			lo++
+		}
+	}
+	return dst
+}
+
+// intersectAlloc returns a new slice per call; it's the same algorithm, so
+// any difference is the allocation.
+func intersectAlloc(a, b []uint32) []uint32 {
+	var out []uint32
+	return AppendIntersection(out, a, b)
+}
+
+// referenceIntersection uses a set, independent of input order.
+func referenceIntersection(a, b []uint32) []uint32 {
+	in := make(map[uint32]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	var out []uint32
+	for _, v := range a {
+		if in[v] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func TestIntersectionCases(t *testing.T) {
+	cases := []struct {
+		name    string
+		a, b    []uint32
+		wantLen int
+	}{
+		{"both empty", nil, nil, 0},
+		{"one empty", nil, []uint32{1, 2, 3}, 0},
+		{"disjoint", []uint32{1, 3, 5}, []uint32{2, 4, 6}, 0},
+		{"disjoint ranges", []uint32{1, 2, 3}, []uint32{10, 11, 12}, 0},
+		{"identical", []uint32{2, 4, 8, 16}, []uint32{2, 4, 8, 16}, 4},
+		{"subset", []uint32{4, 8}, []uint32{1, 2, 4, 6, 8, 10}, 2},
+		{"edges", []uint32{0, 500, 1<<32 - 1}, []uint32{0, 1, 2, 1<<32 - 1}, 2},
+		{"gallop", []uint32{6, 900, 4000}, intersectRange(0, 5000, 3), 2},
+		{"gallop past end", []uint32{3, 1 << 20}, intersectRange(0, 1000, 1), 1},
+	}
+	for _, c := range cases {
+		for _, order := range [][2][]uint32{{c.a, c.b}, {c.b, c.a}} {
+			got := AppendIntersection(nil, order[0], order[1])
+			if want := referenceIntersection(order[0], order[1]); !slices.Equal(got, want) {
+				t.Fatalf("%s: got %v, want %v", c.name, got, want)
+			}
+			if len(got) != c.wantLen {
+				t.Fatalf("%s: %d values in common, want %d", c.name, len(got), c.wantLen)
+			}
+		}
+	}
+}
+
+func TestIntersectionRandomPreservesOrder(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 3))
+	for range 300 {
+		a := randomPostings(rng, 1+rng.IntN(2000), 1+rng.IntN(100_000))
+		b := randomPostings(rng, 1+rng.IntN(2000), 1+rng.IntN(100_000))
+		got := AppendIntersection(nil, a, b)
+		if want := referenceIntersection(a, b); !slices.Equal(got, want) {
+			t.Fatalf("|a|=%d |b|=%d: got %d values, want %d", len(a), len(b), len(got), len(want))
+		}
+		if !slices.IsSorted(got) {
+			t.Fatal("intersection is not sorted")
+		}
+		if alloc := intersectAlloc(a, b); !slices.Equal(alloc, got) {
+			t.Fatal("intersectAlloc and AppendIntersection disagree")
+		}
+		// Both strategies must agree regardless of which one the ratio picks.
+		if merged := appendMerge(nil, a, b); !slices.Equal(merged, got) {
+			t.Fatal("merge and the chosen strategy disagree")
+		}
+		small, large := a, b
+		if len(small) > len(large) {
+			small, large = large, small
+		}
+		if galloped := appendGallop(nil, small, large); !slices.Equal(galloped, got) {
+			t.Fatal("gallop and the chosen strategy disagree")
+		}
+	}
+}
+
+func TestIntersectionKeepsPrefix(t *testing.T) {
+	got := AppendIntersection([]uint32{99}, []uint32{1, 2, 3}, []uint32{2, 3, 4})
+	if want := []uint32{99, 2, 3}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestIntersectionReusedBufferDoesNotAllocate(t *testing.T) {
+	var dst []uint32
+	for _, p := range intersectBenchPairs {
+		dst = AppendIntersection(dst[:0], p[0], p[1])
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, p := range intersectBenchPairs {
+			dst = AppendIntersection(dst[:0], p[0], p[1])
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("intersections into a reused buffer allocated %v times", allocs)
+	}
+}
+
+func intersectRange(from, to, step uint32) []uint32 {
+	var out []uint32
+	for v := from; v < to; v += step {
+		out = append(out, v)
+	}
+	return out
+}
+
+// randomPostings returns n distinct sorted IDs below limit, or fewer if
+// limit < n.
+func randomPostings(rng *rand.Rand, n, limit int) []uint32 {
+	n = min(n, limit)
+	seen := make(map[uint32]bool, n)
+	out := make([]uint32, 0, n)
+	for len(out) < n {
+		v := uint32(rng.IntN(limit))
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// intersectBenchPairs model the term pairs of search queries over one
+// 65,536-document index segment: terms with 100 to 5,000 postings, and every
+// fourth pair joins one with a rare term of 10 to 100 postings.
+const intersectSegment = 1 << 16
+
+var (
+	intersectBenchPairs = func() [][2][]uint32 {
+		rng := rand.New(rand.NewPCG(17, 17))
+		pairs := make([][2][]uint32, 1000)
+		for i := range pairs {
+			a := randomPostings(rng, 100+rng.IntN(4900), intersectSegment)
+			n := 100 + rng.IntN(4900)
+			if i%4 == 0 {
+				n = 10 + rng.IntN(90)
+			}
+			pairs[i] = [2][]uint32{a, randomPostings(rng, n, intersectSegment)}
+		}
+		return pairs
+	}()
+	intersectLenSink int
+)
+
+// Each operation intersects all 1,000 pairs.
+
+func BenchmarkIntersectAllocPerPair(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, p := range intersectBenchPairs {
+			n += len(intersectAlloc(p[0], p[1]))
+		}
+		intersectLenSink = n
+	}
+}
+
+func BenchmarkIntersectReusedBuffer(b *testing.B) {
+	var dst []uint32
+	for _, p := range intersectBenchPairs {
+		dst = AppendIntersection(dst[:0], p[0], p[1])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, p := range intersectBenchPairs {
+			dst = AppendIntersection(dst[:0], p[0], p[1])
+			n += len(dst)
+		}
+		intersectLenSink = n
+	}
+}
@@ -0,0 +1,214 @@
+package perf
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// rowSource is the subset of *sql.Rows used by the scanners below.
+type rowSource interface {
+	Next() bool
+	Scan(dest ...any) error
+}
+
+// fakeRows stands in for *sql.Rows over an in-memory result set. A nil
+// value represents SQL NULL.
+type fakeRows struct {
+	data [][]any
+	pos  int
+}
+
+func (r *fakeRows) Next() bool {
+	r.pos++
+	return r.pos <= len(r.data)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	row := r.data[r.pos-1]
+	if len(dest) != len(row) {
+		return fmt.Errorf("expected %d destination arguments, got %d", len(row), len(dest))
+	}
+	for i, v := range row {
+		switch d := dest[i].(type) {
+		case *int64:
+			*d = v.(int64)
+		case *string:
+			*d = v.(string)
+		case *float64:
+			*d = v.(float64)
+		case *bool:
+			*d = v.(bool)
+		case *sql.NullString:
+			d.String, d.Valid = "", v != nil
+			if v != nil {
+				d.String = v.(string)
+			}
+		default:
+			return fmt.Errorf("unsupported destination %T", dest[i])
+		}
+	}
+	return nil
+}
+
+func (r *fakeRows) rewind() { r.pos = 0 }
+
+type userRow struct {
+	ID     int64
+	Name   string
+	Email  sql.NullString
+	Score  float64
+	Active bool
+}
+
+// scanUsersFresh allocates a new row struct and scan-target slice per row.
+func scanUsersFresh(rows rowSource, fn func(*userRow)) error {
+	for rows.Next() {
+		u := &userRow{}
+		dest := []any{&u.ID, &u.Name, &u.Email, &u.Score, &u.Active}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		fn(u)
+	}
+	return nil
+}
+
+// userScanner binds its scan targets to its own row once; every row is
+// scanned into the same struct through the same slice.
+type userScanner struct {
+	row  userRow
+	dest []any
+}
+
+func newUserScanner() *userScanner {
+	s := &userScanner{}
+	s.dest = []any{&s.row.ID, &s.row.Name, &s.row.Email, &s.row.Score, &s.row.Active}
+	return s
+}
+
+var userScannerPool = sync.Pool{
+	New: func() any { return newUserScanner() },
+}
+
+// scanUsersReused reuses one pooled scanner for the whole result set. The
+// row passed to fn is overwritten by the next row; copy it to keep it.
+func scanUsersReused(rows rowSource, fn func(*userRow)) error {
+	s := userScannerPool.Get().(*userScanner)
+	defer func() {
+		s.row = userRow{}
+		userScannerPool.Put(s)
+	}()
+	for rows.Next() {
+		s.row = userRow{} // no column can inherit a value from the previous row
+		if err := rows.Scan(s.dest...); err != nil {
+			return err
+		}
+		fn(&s.row)
+	}
+	return nil
+}
+
+func makeUserRows(n int) *fakeRows {
+	data := make([][]any, n)
+	for i := range data {
+		var email any
+		if i%3 != 0 {
+			email = "user" + strconv.Itoa(i) + "@example.com"
+		}
+		data[i] = []any{int64(i), "user-" + strconv.Itoa(i), email, float64(i%100) / 4, i%2 == 0}
+	}
+	return &fakeRows{data: data}
+}
+
+func TestScanUsersCorrectValues(t *testing.T) {
+	rows := makeUserRows(1000)
+	for name, scan := range map[string]func(rowSource, func(*userRow)) error{
+		"fresh":  scanUsersFresh,
+		"reused": scanUsersReused,
+	} {
+		rows.rewind()
+		i := 0
+		err := scan(rows, func(u *userRow) {
+			want := userRow{
+				ID:     int64(i),
+				Name:   "user-" + strconv.Itoa(i),
+				Score:  float64(i%100) / 4,
+				Active: i%2 == 0,
+			}
+			if i%3 != 0 {
+				want.Email = sql.NullString{String: "user" + strconv.Itoa(i) + "@example.com", Valid: true}
+			}
+			if *u != want {
+				t.Fatalf("%s row %d: got %+v, want %+v", name, i, *u, want)
+			}
+			i++
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != 1000 {
+			t.Fatalf("%s: scanned %d rows", name, i)
+		}
+	}
+}
+
+func TestScanUsersReusedNoLeak(t *testing.T) {
+	// A NULL email right after a non-NULL one must not inherit the old value.
+	rows := &fakeRows{data: [][]any{
+		{int64(1), "alice", "alice@example.com", 1.5, true},
+		{int64(2), "bob", nil, 2.5, false},
+	}}
+	var got []userRow
+	if err := scanUsersReused(rows, func(u *userRow) { got = append(got, *u) }); err != nil {
+		t.Fatal(err)
+	}
+	if got[1].Email.Valid || got[1].Email.String != "" {
+		t.Fatalf("row 2 inherited email %+v", got[1].Email)
+	}
+
+	s := userScannerPool.Get().(*userScanner)
+	defer userScannerPool.Put(s)
+	if s.row != (userRow{}) {
+		t.Fatalf("pooled scanner holds stale row %+v", s.row)
+	}
+	if s.dest[0] != &s.row.ID {
+		t.Fatal("scan targets are not bound to the scanner's own row")
+	}
+}
+
+func TestScanUsersReusedAllocations(t *testing.T) {
+	rows := makeUserRows(100)
+	allocs := testing.AllocsPerRun(100, func() {
+		rows.rewind()
+		_ = scanUsersReused(rows, func(*userRow) {})
+	})
+	if allocs != 0 {
+		t.Fatalf("scanning 100 rows allocated %v times", allocs)
+	}
+}
+
+var (
+	userRowsBench = makeUserRows(100_000)
+	userScoreSum  float64
+)
+
+func BenchmarkSQLScanFreshPerRow(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		userRowsBench.rewind()
+		sum := 0.0
+		_ = scanUsersFresh(userRowsBench, func(u *userRow) { sum += u.Score })
+		userScoreSum = sum
+	}
+}
+
+func BenchmarkSQLScanReused(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		userRowsBench.rewind()
+		sum := 0.0
+		_ = scanUsersReused(userRowsBench, func(u *userRow) { sum += u.Score })
+		userScoreSum = sum
+	}
+}
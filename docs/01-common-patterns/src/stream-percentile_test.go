@@ -0,0 +1,182 @@
+package perf
+
+import (
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+const (
+	percentileStreamLen  = 1 << 20
+	percentileSampleSize = 4096
+)
+
+// Reservoir keeps a fixed-size uniform random sample of a stream (Algorithm R)
+// and answers quantile queries from it. Both the sample and the sort scratch
+// are allocated once, so memory stays bounded no matter how long the stream is.
+type Reservoir struct {
+	sample  []float64
+	scratch []float64
+	seen    uint64
+	rng     *rand.Rand
+}
+
+func NewReservoir(size int, seed uint64) *Reservoir {
+	return &Reservoir{
+		sample:  make([]float64, 0, size),
+		scratch: make([]float64, size),
+		rng:     rand.New(rand.NewPCG(seed, seed+1)),
+	}
+}
+
+// Add observes one value from the stream. It never allocates.
+func (r *Reservoir) Add(v float64) {
+	r.seen++
+	if len(r.sample) < cap(r.sample) {
+		r.sample = append(r.sample, v)
+		return
+	}
+	if j := r.rng.Uint64N(r.seen); j < uint64(len(r.sample)) {
+		r.sample[j] = v
+	}
+}
+
+// Quantile returns the estimated q-quantile (0 <= q <= 1) of all values seen so far.
+func (r *Reservoir) Quantile(q float64) float64 {
+	if len(r.sample) == 0 {
+		return math.NaN()
+	}
+	s := r.scratch[:len(r.sample)]
+	copy(s, r.sample)
+	slices.Sort(s)
+	return s[quantileIndex(len(s), q)]
+}
+
+// Reset forgets the stream while keeping the preallocated storage.
+func (r *Reservoir) Reset() {
+	r.sample = r.sample[:0]
+	r.seen = 0
+}
+
+func quantileIndex(n int, q float64) int {
+	return int(q * float64(n-1))
+}
+
+// exactQuantile is the naive reference: it sorts every value it is given.
+func exactQuantile(values []float64, q float64) float64 {
+	slices.Sort(values)
+	return values[quantileIndex(len(values), q)]
+}
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func TestReservoirQuantileUniform(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 11))
+	r := NewReservoir(percentileSampleSize, 42)
+	all := make([]float64, 0, percentileStreamLen)
+	for i := 0; i < percentileStreamLen; i++ {
+		v := rng.Float64()
+		r.Add(v)
+		all = append(all, v)
+	}
+
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		got := r.Quantile(q)
+		want := exactQuantile(all, q)
+		if math.Abs(got-want) > 0.02 {
+			t.Errorf("p%v: estimate %.4f, exact %.4f", q*100, got, want)
+		}
+		// The exact quantile of U(0,1) is q itself.
+		if math.Abs(want-q) > 0.01 {
+			t.Errorf("p%v: exact %.4f is too far from analytical %.4f", q*100, want, q)
+		}
+	}
+}
+
+func TestReservoirShortStreamIsExact(t *testing.T) {
+	r := NewReservoir(16, 1)
+	values := []float64{9, 3, 7, 1, 5}
+	for _, v := range values {
+		r.Add(v)
+	}
+	for _, q := range []float64{0, 0.5, 1} {
+		if got, want := r.Quantile(q), exactQuantile(slices.Clone(values), q); got != want {
+			t.Errorf("q=%v: got %v, want %v", q, got, want)
+		}
+	}
+
+	r.Reset()
+	if !math.IsNaN(r.Quantile(0.5)) {
+		t.Error("expected NaN after Reset")
+	}
+}
+
+func TestReservoirAddDoesNotAllocate(t *testing.T) {
+	r := NewReservoir(percentileSampleSize, 3)
+	v := 0.0
+	allocs := testing.AllocsPerRun(10000, func() {
+		r.Add(v)
+		v++
+	})
+	if allocs != 0 {
+		t.Fatalf("Add allocated %v times per call", allocs)
+	}
+}
+
+var percentileSink float64
+
+func BenchmarkPercentileCollectAndSort(b *testing.B) {
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		base := heapAlloc()
+		rng := rand.New(rand.NewPCG(1, 2))
+		b.StartTimer()
+
+		var all []float64
+		for j := 0; j < percentileStreamLen; j++ {
+			all = append(all, rng.Float64())
+		}
+		percentileSink = exactQuantile(all, 0.99)
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		runtime.KeepAlive(all)
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+}
+
+func BenchmarkPercentileReservoir(b *testing.B) {
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		base := heapAlloc()
+		rng := rand.New(rand.NewPCG(1, 2))
+		b.StartTimer()
+
+		r := NewReservoir(percentileSampleSize, 42)
+		for j := 0; j < percentileStreamLen; j++ {
+			r.Add(rng.Float64())
+		}
+		percentileSink = r.Quantile(0.99)
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		runtime.KeepAlive(r)
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+}
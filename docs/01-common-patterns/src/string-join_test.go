@@ -0,0 +1,102 @@
+package perf
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var joinParts = func() []string {
+	parts := make([]string, 1000)
+	for i := range parts {
+		parts[i] = "field-" + strconv.Itoa(i*7919)
+	}
+	return parts
+}()
+
+// joinGrowing appends into a Builder without sizing it first, so the
+// underlying buffer is reallocated every time it runs out of room.
+func joinGrowing(parts []string, sep string) string {
+	var sb strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+// joinPresized computes the exact output length in a first pass and grows
+// the Builder once, so the second pass only copies bytes.
+func joinPresized(parts []string, sep string) string {
+	if len(parts) == 0 {
+		return ""
+	}
+	n := len(sep) * (len(parts) - 1)
+	for _, p := range parts {
+		n += len(p)
+	}
+
+	var sb strings.Builder
+	sb.Grow(n)
+	sb.WriteString(parts[0])
+	for _, p := range parts[1:] {
+		sb.WriteString(sep)
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+func TestJoinPresizedMatchesStringsJoin(t *testing.T) {
+	cases := [][]string{
+		nil,
+		{},
+		{""},
+		{"only"},
+		{"a", "b", "c"},
+		{"", "", ""},
+		{"x", "", "y"},
+		joinParts,
+	}
+	for _, sep := range []string{"", ",", " | "} {
+		for _, parts := range cases {
+			want := strings.Join(parts, sep)
+			if got := joinPresized(parts, sep); got != want {
+				t.Errorf("joinPresized(%d parts, %q) = %q, want %q", len(parts), sep, got, want)
+			}
+			if got := joinGrowing(parts, sep); got != want {
+				t.Errorf("joinGrowing(%d parts, %q) = %q, want %q", len(parts), sep, got, want)
+			}
+		}
+	}
+}
+
+func TestJoinPresizedAllocatesOnce(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = joinPresized(joinParts, ", ")
+	})
+	if allocs != 1 {
+		t.Fatalf("joinPresized allocated %v times, want 1", allocs)
+	}
+}
+
+var joinSink string
+
+func BenchmarkJoinBuilderGrowing(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		joinSink = joinGrowing(joinParts, ", ")
+	}
+}
+
+func BenchmarkJoinStringsJoin(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		joinSink = strings.Join(joinParts, ", ")
+	}
+}
+
+func BenchmarkJoinBuilderPresized(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		joinSink = joinPresized(joinParts, ", ")
+	}
+}
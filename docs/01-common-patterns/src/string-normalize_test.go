@@ -0,0 +1,176 @@
+package perf
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// normalizeStrings lowercases s, trims it, and collapses every run of
+// whitespace into one space, using one strings call per step.
+func normalizeStrings(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// normSpace marks the ASCII bytes unicode.IsSpace accepts.
+var normSpace = [256]bool{'\t': true, '\n': true, '\v': true, '\f': true, '\r': true, ' ': true}
+
+// AppendNormalized appends the normalized form of s to dst in a single
+// pass. Input containing non-ASCII bytes falls back to normalizeStrings,
+// which handles Unicode case mapping and spaces.
+func AppendNormalized(dst []byte, s string) []byte {
+	start := len(dst)
+	pendingSpace := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			return append(dst[:start], normalizeStrings(s)...)
+		}
+		if normSpace[c] {
+			pendingSpace = len(dst) > start
+			continue
+		}
+		if pendingSpace {
+			dst = append(dst, ' ')
+			pendingSpace = false
+		}
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
+
+// Normalizer reuses one scratch buffer for every string.
+type Normalizer struct {
+	buf []byte
+}
+
+// Normalize returns the normalized form of s. The result is only valid
+// until the next call.
+func (n *Normalizer) Normalize(s string) []byte {
+	n.buf = AppendNormalized(n.buf[:0], s)
+	return n.buf
+}
+
+func TestNormalizeEdgeCases(t *testing.T) {
+	cases := []string{
+		"",
+		" ",
+		"\t\n\v\f\r ",
+		"hello",
+		"HELLO",
+		"  Hello   World  ",
+		"a\tb\nc\rd\fe\vf",
+		"x",
+		" X ",
+		"already normalized text",
+		"Mixed CASE with  DOUBLE  spaces",
+		"trailing tab\t",
+		"\nleading newline",
+		"digits 123 and_PUNCT-uation!",
+		"\x00control\x1fbytes\x7f",
+		"Café  AU Lait",                // non-ASCII letter
+		"non\u00a0breaking\u2003space", // Unicode spaces
+		"ÀÉÎ ÕÜ",
+	}
+	var n Normalizer
+	for _, s := range cases {
+		want := normalizeStrings(s)
+		if got := n.Normalize(s); string(got) != want {
+			t.Errorf("Normalize(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestNormalizeMatchesReferenceOnRandomInput(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	alphabet := "aZ09 \t\n\r\v\f-_."
+	var n Normalizer
+	for i := 0; i < 20_000; i++ {
+		b := make([]byte, rng.IntN(40))
+		for j := range b {
+			b[j] = alphabet[rng.IntN(len(alphabet))]
+		}
+		s := string(b)
+		if got, want := string(n.Normalize(s)), normalizeStrings(s); got != want {
+			t.Fatalf("Normalize(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestAppendNormalizedKeepsPrefix(t *testing.T) {
+	dst := []byte("key=")
+	for _, s := range []string{"  Foo  BAR ", "Ünïcode  Input"} {
+		got := string(AppendNormalized(dst, s))
+		if want := "key=" + normalizeStrings(s); got != want {
+			t.Fatalf("AppendNormalized(%q, %q) = %q, want %q", dst, s, got, want)
+		}
+	}
+}
+
+func TestNormalizerDoesNotAllocate(t *testing.T) {
+	var n Normalizer
+	n.Normalize(strings.Repeat("x", 64))
+	allocs := testing.AllocsPerRun(100, func() { n.Normalize("  Search   QUERY\twith Tabs ") })
+	if allocs != 0 {
+		t.Fatalf("Normalize allocated %v times", allocs)
+	}
+}
+
+// normBenchInputs look like user-typed search queries and tags: mixed case,
+// stray spaces and tabs, ASCII only.
+var (
+	normBenchInputs = makeNormInputs(10_000)
+	normLenSink     int
+)
+
+func makeNormInputs(n int) []string {
+	words := []string{"Go", "performance", "GUIDE", "sync.Pool", "Escape", "analysis", "HTTP", "json", "Zero-Copy", "bench"}
+	seps := []string{" ", "  ", "\t", " \t ", "   "}
+	rng := rand.New(rand.NewPCG(9, 9))
+	out := make([]string, n)
+	for i := range out {
+		var sb strings.Builder
+		if i%3 == 0 {
+			sb.WriteString("  ")
+		}
+		for w := 0; w < 2+rng.IntN(5); w++ {
+			if w > 0 {
+				sb.WriteString(seps[rng.IntN(len(seps))])
+			}
+			sb.WriteString(words[rng.IntN(len(words))])
+		}
+		fmt.Fprintf(&sb, " %d ", i%100)
+		out[i] = sb.String()
+	}
+	return out
+}
+
+// Each operation normalizes all 10,000 inputs.
+
+func BenchmarkNormalizeStrings(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		total := 0
+		for _, s := range normBenchInputs {
+			total += len(normalizeStrings(s))
+		}
+		normLenSink = total
+	}
+}
+
+func BenchmarkNormalizeSinglePass(b *testing.B) {
+	var n Normalizer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		total := 0
+		for _, s := range normBenchInputs {
+			total += len(n.Normalize(s))
+		}
+		normLenSink = total
+	}
+}
@@ -0,0 +1,300 @@
+package perf
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pageTemplate is parsed once at startup; only execution happens per render.
+var pageTemplate = template.Must(template.New("page").Parse(
+	`<h1>{{.Title}}</h1><p>Hello, {{.User}}</p><ul>{{range .Items}}<li>{{.Name}} x{{.Qty}}</li>{{end}}</ul>`))
+
+type renderItem struct {
+	Name string
+	Qty  int
+}
+
+// renderContext is the data passed to the template for one render.
+type renderContext struct {
+	Title string
+	User  string
+	Items []renderItem
+}
+
+// reset clears every field so nothing from the previous render survives.
+// The Items backing array is kept and reused.
+func (c *renderContext) reset() {
+	c.Title = ""
+	c.User = ""
+	clear(c.Items)
+	c.Items = c.Items[:0]
+}
+
+// renderRequest is the input a handler would have decoded from a request.
+type renderRequest struct {
+	user  string
+	names []string
+}
+
+func fillRenderContext(c *renderContext, req *renderRequest) {
+	c.Title = "Your cart"
+	c.User = req.user
+	for i, name := range req.names {
+		c.Items = append(c.Items, renderItem{Name: name, Qty: i + 1})
+	}
+}
+
+// renderTemplateFresh allocates the output buffer and the context per render.
+func renderTemplateFresh(w io.Writer, req *renderRequest) error {
+	ctx := &renderContext{}
+	fillRenderContext(ctx, req)
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, ctx); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var (
+	renderBufferPool = sync.Pool{
+		New: func() any { return bytes.NewBuffer(make([]byte, 0, 2048)) },
+	}
+	renderContextPool = sync.Pool{
+		New: func() any { return &renderContext{Items: make([]renderItem, 0, 16)} },
+	}
+)
+
+// maxPooledRenderBuffer keeps one unusually large page from pinning a large
+// buffer in the pool forever.
+const maxPooledRenderBuffer = 64 << 10
+
+func releaseRenderBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledRenderBuffer {
+		return
+	}
+	buf.Reset()
+	renderBufferPool.Put(buf)
+}
+
+// renderTemplatePooled draws both the buffer and the context from pools and
+// resets them before returning them.
+func renderTemplatePooled(w io.Writer, req *renderRequest) error {
+	ctx := renderContextPool.Get().(*renderContext)
+	buf := renderBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		ctx.reset()
+		renderContextPool.Put(ctx)
+		releaseRenderBuffer(buf)
+	}()
+
+	fillRenderContext(ctx, req)
+	if err := pageTemplate.Execute(buf, ctx); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// renderPageCompiled is the same page compiled by hand into Go code, the
+// approach taken by template generators such as quicktemplate or templ. It
+// writes into the caller's buffer without reflection.
+func renderPageCompiled(buf *bytes.Buffer, c *renderContext) {
+	var num [20]byte
+	buf.WriteString("<h1>")
+	writeHTMLEscaped(buf, c.Title)
+	buf.WriteString("</h1><p>Hello, ")
+	writeHTMLEscaped(buf, c.User)
+	buf.WriteString("</p><ul>")
+	for _, it := range c.Items {
+		buf.WriteString("<li>")
+		writeHTMLEscaped(buf, it.Name)
+		buf.WriteString(" x")
+		buf.Write(strconv.AppendInt(num[:0], int64(it.Qty), 10))
+		buf.WriteString("</li>")
+	}
+	buf.WriteString("</ul>")
+}
+
+// writeHTMLEscaped escapes the same characters as html/template does in
+// HTML text context.
+func writeHTMLEscaped(buf *bytes.Buffer, s string) {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch s[i] {
+		case 0:
+			esc = "\uFFFD"
+		case '"':
+			esc = "&#34;"
+		case '&':
+			esc = "&amp;"
+		case '\'':
+			esc = "&#39;"
+		case '+':
+			esc = "&#43;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			esc = "&gt;"
+		default:
+			continue
+		}
+		buf.WriteString(s[last:i])
+		buf.WriteString(esc)
+		last = i + 1
+	}
+	buf.WriteString(s[last:])
+}
+
+func renderCompiledPooled(w io.Writer, req *renderRequest) error {
+	ctx := renderContextPool.Get().(*renderContext)
+	buf := renderBufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		ctx.reset()
+		renderContextPool.Put(ctx)
+		releaseRenderBuffer(buf)
+	}()
+
+	fillRenderContext(ctx, req)
+	renderPageCompiled(buf, ctx)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var templateRequest = func() *renderRequest {
+	req := &renderRequest{user: "alice"}
+	for i := 0; i < 10; i++ {
+		req.names = append(req.names, "item-"+strconv.Itoa(i))
+	}
+	return req
+}()
+
+func TestTemplatePooledMatchesFresh(t *testing.T) {
+	var fresh, pooled bytes.Buffer
+	if err := renderTemplateFresh(&fresh, templateRequest); err != nil {
+		t.Fatal(err)
+	}
+	if err := renderTemplatePooled(&pooled, templateRequest); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.String() != pooled.String() {
+		t.Fatalf("pooled output differs:\n fresh  %s\n pooled %s", fresh.String(), pooled.String())
+	}
+	want := `<h1>Your cart</h1><p>Hello, alice</p><ul><li>item-0 x1</li>`
+	if !strings.HasPrefix(fresh.String(), want) {
+		t.Errorf("unexpected output: %s", fresh.String())
+	}
+}
+
+func TestTemplateCompiledMatchesHTMLTemplate(t *testing.T) {
+	reqs := []*renderRequest{
+		templateRequest,
+		{user: "<b>\"Tom\" & 'Jerry'</b> +1 \x00", names: []string{"a<b", "c&d"}},
+		{user: ""},
+	}
+	for _, req := range reqs {
+		var want, got bytes.Buffer
+		if err := renderTemplateFresh(&want, req); err != nil {
+			t.Fatal(err)
+		}
+		if err := renderCompiledPooled(&got, req); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("compiled output differs:\n got  %s\n want %s", got.String(), want.String())
+		}
+	}
+}
+
+func TestTemplatePooledEscapesInput(t *testing.T) {
+	var out bytes.Buffer
+	req := &renderRequest{user: "<script>alert(1)</script>"}
+	if err := renderTemplatePooled(&out, req); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "<script>") {
+		t.Fatalf("user input was not escaped: %s", out.String())
+	}
+}
+
+func TestTemplateContextNoCrossRenderLeak(t *testing.T) {
+	big := &renderRequest{user: "bob", names: []string{"secret-a", "secret-b", "secret-c"}}
+	small := &renderRequest{user: "carol", names: []string{"only"}}
+	for i := 0; i < 100; i++ {
+		var out bytes.Buffer
+		if err := renderTemplatePooled(&out, big); err != nil {
+			t.Fatal(err)
+		}
+		out.Reset()
+		if err := renderTemplatePooled(&out, small); err != nil {
+			t.Fatal(err)
+		}
+		s := out.String()
+		if strings.Contains(s, "secret") || strings.Contains(s, "bob") {
+			t.Fatalf("render leaked data from a previous render: %s", s)
+		}
+		if strings.Count(s, "<li>") != 1 {
+			t.Fatalf("expected exactly one item: %s", s)
+		}
+	}
+}
+
+func TestRenderContextReset(t *testing.T) {
+	c := &renderContext{Items: make([]renderItem, 0, 4)}
+	fillRenderContext(c, &renderRequest{user: "dave", names: []string{"a", "b"}})
+	backing := c.Items[:cap(c.Items)]
+	c.reset()
+	if c.Title != "" || c.User != "" || len(c.Items) != 0 {
+		t.Fatalf("context not reset: %+v", c)
+	}
+	for _, it := range backing {
+		if it != (renderItem{}) {
+			t.Fatalf("stale item in backing array: %+v", it)
+		}
+	}
+}
+
+func TestOversizedRenderBufferDropped(t *testing.T) {
+	buf := bytes.NewBuffer(make([]byte, 0, 2*maxPooledRenderBuffer))
+	buf.WriteString("large")
+	releaseRenderBuffer(buf)
+	if buf.Len() == 0 {
+		t.Fatal("oversized buffer should be left alone, not reset and pooled")
+	}
+}
+
+func BenchmarkTemplateRenderFresh(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := io.Discard
+		for pb.Next() {
+			_ = renderTemplateFresh(w, templateRequest)
+		}
+	})
+}
+
+func BenchmarkTemplateRenderPooled(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := io.Discard
+		for pb.Next() {
+			_ = renderTemplatePooled(w, templateRequest)
+		}
+	})
+}
+
+func BenchmarkTemplateRenderCompiledPooled(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := io.Discard
+		for pb.Next() {
+			_ = renderCompiledPooled(w, templateRequest)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package perf
+
+import (
+	"strings"
+	"testing"
+)
+
+type TokenKind uint8
+
+const (
+	TokEOF TokenKind = iota
+	TokIdent
+	TokNumber
+	TokOperator
+	TokPunct
+)
+
+type Token struct {
+	Kind TokenKind
+	Text []byte // slice of the input
+	Pos  int
+}
+
+// scanToken reads the token starting at or after pos and returns its kind,
+// start, and end offsets.
+func scanToken(src []byte, pos int) (TokenKind, int, int) {
+	for pos < len(src) && (src[pos] == ' ' || src[pos] == '\t' || src[pos] == '\n') {
+		pos++
+	}
+	if pos == len(src) {
+		return TokEOF, pos, pos
+	}
+	start := pos
+	c := src[pos]
+	switch {
+	case isTokenIdentByte(c):
+		for pos < len(src) && (isTokenIdentByte(src[pos]) || isTokenDigit(src[pos])) {
+			pos++
+		}
+		return TokIdent, start, pos
+	case isTokenDigit(c):
+		for pos < len(src) && (isTokenDigit(src[pos]) || src[pos] == '.') {
+			pos++
+		}
+		return TokNumber, start, pos
+	case strings.IndexByte("+-*/=<>!&|", c) >= 0:
+		pos++
+		if pos < len(src) && strings.IndexByte("=&|", src[pos]) >= 0 {
+			pos++
+		}
+		return TokOperator, start, pos
+	default:
+		return TokPunct, start, pos + 1
+	}
+}
+
+func isTokenIdentByte(c byte) bool { return c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z') }
+
+func isTokenDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// --- Allocating tokenizer ---
+
+type AllocTokenizer struct {
+	src []byte
+	pos int
+}
+
+// Next returns a newly allocated token that the caller may keep forever.
+func (t *AllocTokenizer) Next() *Token {
+	kind, start, end := scanToken(t.src, t.pos)
+	t.pos = end
+	return &Token{Kind: kind, Text: t.src[start:end], Pos: start}
+}
+
+// --- Ring tokenizer ---
+
+// tokenRingSize is how many tokens stay valid at once.
+const tokenRingSize = 8
+
+// RingTokenizer yields tokens from a fixed ring of reusable Token structs.
+//
+// Ownership contract: a *Token returned by Next is valid until Next has been
+// called tokenRingSize more times. After that its slot is reused and its
+// fields are overwritten. Callers that need a token longer must copy it
+// (tok := *t), which is a plain value copy with no allocation.
+type RingTokenizer struct {
+	src  []byte
+	pos  int
+	ring [tokenRingSize]Token
+	next int
+}
+
+func (t *RingTokenizer) Next() *Token {
+	kind, start, end := scanToken(t.src, t.pos)
+	t.pos = end
+	tok := &t.ring[t.next]
+	t.next = (t.next + 1) % tokenRingSize
+	*tok = Token{Kind: kind, Text: t.src[start:end], Pos: start}
+	return tok
+}
+
+const tokenSample = "if (count >= 10 && ready) { total = total + price * 1.25; emit(total) } else { retry_count = retry_count - 1 }\n"
+
+func collectTokens(next func() *Token) []Token {
+	var out []Token
+	for {
+		tok := next()
+		if tok.Kind == TokEOF {
+			return out
+		}
+		out = append(out, *tok)
+	}
+}
+
+func TestTokenizerSequence(t *testing.T) {
+	src := []byte("x1 >= 42.5 && !done")
+	want := []struct {
+		kind TokenKind
+		text string
+	}{
+		{TokIdent, "x1"}, {TokOperator, ">="}, {TokNumber, "42.5"},
+		{TokOperator, "&&"}, {TokOperator, "!"}, {TokIdent, "done"},
+	}
+	ring := &RingTokenizer{src: src}
+	got := collectTokens(ring.Next)
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Kind != w.kind || string(got[i].Text) != w.text {
+			t.Errorf("token %d: got (%d, %q), want (%d, %q)", i, got[i].Kind, got[i].Text, w.kind, w.text)
+		}
+	}
+}
+
+func TestRingMatchesAllocTokenizer(t *testing.T) {
+	src := []byte(strings.Repeat(tokenSample, 50))
+	want := collectTokens((&AllocTokenizer{src: src}).Next)
+	got := collectTokens((&RingTokenizer{src: src}).Next)
+	if len(got) != len(want) {
+		t.Fatalf("ring produced %d tokens, alloc produced %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Kind != want[i].Kind || got[i].Pos != want[i].Pos || string(got[i].Text) != string(want[i].Text) {
+			t.Fatalf("token %d: ring %+v, alloc %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRingTokenLifetime(t *testing.T) {
+	ring := &RingTokenizer{src: []byte(strings.Repeat("a ", tokenRingSize+1))}
+
+	first := ring.Next()
+	saved := *first // copying is how a caller keeps a token
+	for i := 0; i < tokenRingSize-1; i++ {
+		ring.Next()
+	}
+	if first.Pos != saved.Pos {
+		t.Fatal("token was overwritten before tokenRingSize further calls")
+	}
+
+	ring.Next() // reuses first's slot
+	if first.Pos == saved.Pos {
+		t.Fatal("expected the ring slot to be reused after tokenRingSize calls")
+	}
+	if saved.Pos != 0 || string(saved.Text) != "a" {
+		t.Fatalf("copied token changed: %+v", saved)
+	}
+}
+
+func TestRingTokenizerDoesNotAllocate(t *testing.T) {
+	src := []byte(strings.Repeat(tokenSample, 10))
+	allocs := testing.AllocsPerRun(100, func() {
+		ring := RingTokenizer{src: src}
+		for ring.Next().Kind != TokEOF {
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("RingTokenizer allocated %v times", allocs)
+	}
+}
+
+var (
+	tokenBenchInput = []byte(strings.Repeat(tokenSample, 10_000))
+	tokenTextBytes  int
+)
+
+func BenchmarkTokenizerAlloc(b *testing.B) {
+	b.SetBytes(int64(len(tokenBenchInput)))
+	for i := 0; i < b.N; i++ {
+		tz := &AllocTokenizer{src: tokenBenchInput}
+		n := 0
+		for tok := tz.Next(); tok.Kind != TokEOF; tok = tz.Next() {
+			n += len(tok.Text)
+		}
+		tokenTextBytes = n
+	}
+}
+
+func BenchmarkTokenizerRing(b *testing.B) {
+	b.SetBytes(int64(len(tokenBenchInput)))
+	for i := 0; i < b.N; i++ {
+		tz := &RingTokenizer{src: tokenBenchInput}
+		n := 0
+		for tok := tz.Next(); tok.Kind != TokEOF; tok = tz.Next() {
+			n += len(tok.Text)
+		}
+		tokenTextBytes = n
+	}
+}
@@ -0,0 +1,202 @@
+package perf
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+const (
+	topKStreamLen = 10_000_000
+	topKSize      = 100
+)
+
+// TopK keeps the k largest values of a stream in a min-heap of fixed
+// capacity. The smallest kept value sits at the root, so a new value either
+// loses to it immediately or replaces it.
+type TopK struct {
+	heap []uint64
+}
+
+func NewTopK(k int) *TopK { return &TopK{heap: make([]uint64, 0, k)} }
+
+// Offer observes one value. It never allocates.
+func (t *TopK) Offer(v uint64) {
+	h := t.heap
+	if len(h) < cap(h) {
+		h = append(h, v)
+		t.heap = h
+		// Sift up.
+		i := len(h) - 1
+		for i > 0 {
+			parent := (i - 1) / 2
+			if h[parent] <= v {
+				break
+			}
+			h[i] = h[parent]
+			i = parent
+		}
+		h[i] = v
+		return
+	}
+	if len(h) == 0 || v <= h[0] {
+		return
+	}
+	// Replace the root and sift down.
+	i, n := 0, len(h)
+	for {
+		child := 2*i + 1
+		if child >= n {
+			break
+		}
+		if right := child + 1; right < n && h[right] < h[child] {
+			child = right
+		}
+		if v <= h[child] {
+			break
+		}
+		h[i] = h[child]
+		i = child
+	}
+	h[i] = v
+}
+
+// AppendSorted appends the kept values to dst, largest first.
+func (t *TopK) AppendSorted(dst []uint64) []uint64 {
+	start := len(dst)
+	dst = append(dst, t.heap...)
+	slices.Sort(dst[start:])
+	slices.Reverse(dst[start:])
+	return dst
+}
+
+// Reset forgets the stream while keeping the heap's storage.
+func (t *TopK) Reset() { t.heap = t.heap[:0] }
+
+// topKBySort is the reference: it sorts every value and takes the k
+// largest, largest first. It sorts values in place.
+func topKBySort(values []uint64, k int) []uint64 {
+	slices.Sort(values)
+	top := slices.Clone(values[len(values)-min(k, len(values)):])
+	slices.Reverse(top)
+	return top
+}
+
+func TestTopKMatchesSort(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 8))
+	for _, tc := range []struct{ n, k, spread int }{
+		{0, 10, 100},
+		{5, 10, 100},       // fewer values than k
+		{10, 10, 100},      // exactly k
+		{1000, 1, 1 << 30}, // a max tracker
+		{100_000, 100, 1 << 30},
+		{100_000, 100, 50}, // heavy duplicates
+	} {
+		values := make([]uint64, tc.n)
+		top := NewTopK(tc.k)
+		for i := range values {
+			values[i] = uint64(rng.IntN(tc.spread))
+			top.Offer(values[i])
+		}
+		got := top.AppendSorted(nil)
+		if want := topKBySort(values, tc.k); !slices.Equal(got, want) {
+			t.Fatalf("n=%d k=%d: top-K %v, want %v", tc.n, tc.k, got, want)
+		}
+	}
+}
+
+func TestTopKAscendingAndDescendingStreams(t *testing.T) {
+	up, down := NewTopK(3), NewTopK(3)
+	for i := uint64(1); i <= 1000; i++ {
+		up.Offer(i)
+		down.Offer(1001 - i)
+	}
+	want := []uint64{1000, 999, 998}
+	if got := up.AppendSorted(nil); !slices.Equal(got, want) {
+		t.Errorf("ascending stream: %v, want %v", got, want)
+	}
+	if got := down.AppendSorted(nil); !slices.Equal(got, want) {
+		t.Errorf("descending stream: %v, want %v", got, want)
+	}
+	up.Reset()
+	up.Offer(7)
+	if got := up.AppendSorted(nil); !slices.Equal(got, []uint64{7}) {
+		t.Errorf("after Reset: %v, want [7]", got)
+	}
+}
+
+func TestTopKOfferDoesNotAllocate(t *testing.T) {
+	top := NewTopK(topKSize)
+	v := uint64(0)
+	allocs := testing.AllocsPerRun(10000, func() {
+		v = v*6364136223846793005 + 1442695040888963407
+		top.Offer(v)
+	})
+	if allocs != 0 {
+		t.Fatalf("Offer allocated %v times per call", allocs)
+	}
+}
+
+var topKSink []uint64
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// Each operation runs a 10M-value stream through one approach. heap-B is
+// the peak live heap growth, as in the streaming percentiles benchmarks.
+
+func BenchmarkTopKCollectAndSort(b *testing.B) {
+	var peak uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		base := heapAlloc()
+		rng := rand.New(rand.NewPCG(1, 2))
+		b.StartTimer()
+
+		var all []uint64
+		for j := 0; j < topKStreamLen; j++ {
+			all = append(all, rng.Uint64())
+		}
+		topKSink = topKBySort(all, topKSize)
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		runtime.KeepAlive(all)
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+}
+
+func BenchmarkTopKBoundedHeap(b *testing.B) {
+	var peak uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		base := heapAlloc()
+		rng := rand.New(rand.NewPCG(1, 2))
+		b.StartTimer()
+
+		top := NewTopK(topKSize)
+		for j := 0; j < topKStreamLen; j++ {
+			top.Offer(rng.Uint64())
+		}
+		topKSink = top.AppendSorted(nil)
+
+		b.StopTimer()
+		if h := heapAlloc(); h > base && h-base > peak {
+			peak = h - base
+		}
+		runtime.KeepAlive(top)
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(peak), "heap-B")
+}
@@ -0,0 +1,148 @@
+package perf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// uuidSource generates random version 4 UUIDs. A PCG keeps the example
+// deterministic; production code would typically read from crypto/rand.
+type uuidSource struct {
+	rng *rand.PCG
+}
+
+func newUUIDSource(seed uint64) *uuidSource {
+	return &uuidSource{rng: rand.NewPCG(seed, seed^0xa5a5a5a5)}
+}
+
+func (s *uuidSource) Next() [16]byte {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], s.rng.Uint64())
+	binary.BigEndian.PutUint64(id[8:], s.rng.Uint64())
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	return id
+}
+
+// formatUUIDSprintf is the common approach: one fmt.Sprintf per ID.
+func formatUUIDSprintf(id [16]byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
+		id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+const uuidHex = "0123456789abcdef"
+
+// AppendUUID appends the canonical 36-character form of id to dst.
+func AppendUUID(dst []byte, id [16]byte) []byte {
+	for i, b := range id {
+		if i == 4 || i == 6 || i == 8 || i == 10 {
+			dst = append(dst, '-')
+		}
+		dst = append(dst, uuidHex[b>>4], uuidHex[b&0x0f])
+	}
+	return dst
+}
+
+// formatUUID formats into a stack array and converts to a string once.
+func formatUUID(id [16]byte) string {
+	var buf [36]byte
+	return string(AppendUUID(buf[:0], id))
+}
+
+func validUUIDv4(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+				return false
+			}
+		}
+	}
+	return s[14] == '4' && (s[19] == '8' || s[19] == '9' || s[19] == 'a' || s[19] == 'b')
+}
+
+func TestUUIDFormatMatchesSprintf(t *testing.T) {
+	src := newUUIDSource(1)
+	for i := 0; i < 10_000; i++ {
+		id := src.Next()
+		got, want := formatUUID(id), formatUUIDSprintf(id)
+		if got != want {
+			t.Fatalf("formatUUID = %q, Sprintf = %q", got, want)
+		}
+		if !validUUIDv4(got) {
+			t.Fatalf("%q is not a valid version 4 UUID", got)
+		}
+	}
+}
+
+func TestUUIDKnownValue(t *testing.T) {
+	id := [16]byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x42, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}
+	if got, want := formatUUID(id), "123e4567-e89b-42d3-a456-426614174000"; got != want {
+		t.Fatalf("formatUUID = %q, want %q", got, want)
+	}
+}
+
+func TestUUIDsUnique(t *testing.T) {
+	const n = 200_000
+	src := newUUIDSource(2)
+	seen := make(map[string]struct{}, n)
+	for i := 0; i < n; i++ {
+		s := formatUUID(src.Next())
+		if _, dup := seen[s]; dup {
+			t.Fatalf("duplicate UUID %q after %d IDs", s, i)
+		}
+		seen[s] = struct{}{}
+	}
+}
+
+func TestUUIDFormatAllocations(t *testing.T) {
+	id := newUUIDSource(3).Next()
+	if allocs := testing.AllocsPerRun(1000, func() { _ = formatUUID(id) }); allocs != 1 {
+		t.Fatalf("formatUUID allocated %v times, want 1 (the string)", allocs)
+	}
+	buf := make([]byte, 0, 64)
+	if allocs := testing.AllocsPerRun(1000, func() { buf = AppendUUID(buf[:0], id) }); allocs != 0 {
+		t.Fatalf("AppendUUID allocated %v times", allocs)
+	}
+}
+
+var (
+	uuidSink      string
+	uuidBytesSink []byte
+)
+
+func BenchmarkUUIDSprintf(b *testing.B) {
+	src := newUUIDSource(4)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		uuidSink = formatUUIDSprintf(src.Next())
+	}
+}
+
+func BenchmarkUUIDStackBuffer(b *testing.B) {
+	src := newUUIDSource(4)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		uuidSink = formatUUID(src.Next())
+	}
+}
+
+func BenchmarkUUIDAppend(b *testing.B) {
+	src := newUUIDSource(4)
+	buf := make([]byte, 0, 36)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = AppendUUID(buf[:0], src.Next())
+	}
+	uuidBytesSink = buf
+}
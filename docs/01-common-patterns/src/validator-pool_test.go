@@ -0,0 +1,221 @@
+package perf
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FieldError reports one failed rule. Errors for known field/rule pairs are
+// created once, so reporting them doesn't allocate.
+type FieldError struct {
+	Field, Rule string
+}
+
+func (e *FieldError) Error() string { return e.Field + ": " + e.Rule }
+
+var (
+	errEmailRequired  = &FieldError{"email", "required"}
+	errEmailFormat    = &FieldError{"email", "must contain @"}
+	errNameRequired   = &FieldError{"name", "required"}
+	errNameTooLong    = &FieldError{"name", "at most 64 characters"}
+	errAgeRange       = &FieldError{"age", "must be between 13 and 130"}
+	errPasswordLength = &FieldError{"password", "at least 12 characters"}
+	errCountryCode    = &FieldError{"country", "must be a 2-letter code"}
+)
+
+type signupRequest struct {
+	Email, Name, Password, Country string
+	Age                            int
+}
+
+// validateSignup runs every rule and reports failures through add.
+func validateSignup(r *signupRequest, add func(error)) {
+	switch {
+	case r.Email == "":
+		add(errEmailRequired)
+	case !strings.Contains(r.Email, "@"):
+		add(errEmailFormat)
+	}
+	switch {
+	case r.Name == "":
+		add(errNameRequired)
+	case len(r.Name) > 64:
+		add(errNameTooLong)
+	}
+	if r.Age < 13 || r.Age > 130 {
+		add(errAgeRange)
+	}
+	if len(r.Password) < 12 {
+		add(errPasswordLength)
+	}
+	if len(r.Country) != 2 {
+		add(errCountryCode)
+	}
+}
+
+// validateFresh builds a new []error for every request. It runs the same
+// rules as the pooled path, so the two differ only in where errors go.
+func validateFresh(r *signupRequest) []error {
+	var errs []error
+	validateSignup(r, func(err error) { errs = append(errs, err) })
+	return errs
+}
+
+// Validator collects errors into a slice that is reused across validations.
+type Validator struct {
+	errs []error
+}
+
+func (v *Validator) Add(err error) { v.errs = append(v.errs, err) }
+
+// Errors returns the collected errors. The slice is owned by the Validator
+// and is only valid until the next Reset.
+func (v *Validator) Errors() []error { return v.errs }
+
+// Reset empties the collector, clearing old entries so the backing array
+// doesn't keep errors alive.
+func (v *Validator) Reset() {
+	clear(v.errs)
+	v.errs = v.errs[:0]
+}
+
+var validatorPool = sync.Pool{
+	New: func() any { return &Validator{errs: make([]error, 0, 8)} },
+}
+
+func acquireValidator() *Validator { return validatorPool.Get().(*Validator) }
+
+func releaseValidator(v *Validator) {
+	v.Reset()
+	validatorPool.Put(v)
+}
+
+// validatePooled validates r with a pooled collector and passes the errors
+// to handle, which must not retain the slice.
+func validatePooled(r *signupRequest, handle func([]error)) {
+	v := acquireValidator()
+	validateSignup(r, v.Add)
+	handle(v.Errors())
+	releaseValidator(v)
+}
+
+func makeSignupRequests(n int) []signupRequest {
+	reqs := make([]signupRequest, n)
+	for i := range reqs {
+		r := signupRequest{Email: "user@example.com", Name: "Ada", Password: "correct horse battery", Country: "GB", Age: 36}
+		switch i % 4 {
+		case 1:
+			r.Email, r.Age = "user.example.com", 7
+		case 2:
+			r.Name, r.Password, r.Country = "", "short", "GBR"
+		case 3:
+			r.Email, r.Name, r.Age, r.Password, r.Country = "", strings.Repeat("x", 65), 200, "", ""
+		}
+		reqs[i] = r
+	}
+	return reqs
+}
+
+func sameErrors(a, b []error) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestValidatorCollectsErrors(t *testing.T) {
+	reqs := makeSignupRequests(4)
+	want := [][]error{
+		nil,
+		{errEmailFormat, errAgeRange},
+		{errNameRequired, errPasswordLength, errCountryCode},
+		{errEmailRequired, errNameTooLong, errAgeRange, errPasswordLength, errCountryCode},
+	}
+	for i := range reqs {
+		if got := validateFresh(&reqs[i]); !sameErrors(got, want[i]) {
+			t.Errorf("request %d: fresh errors %v, want %v", i, got, want[i])
+		}
+		validatePooled(&reqs[i], func(got []error) {
+			if !sameErrors(got, want[i]) {
+				t.Errorf("request %d: pooled errors %v, want %v", i, got, want[i])
+			}
+		})
+	}
+	var fe *FieldError
+	if !errors.As(errors.Join(want[3]...), &fe) || fe.Field != "email" {
+		t.Fatalf("errors.As on joined errors found %v", fe)
+	}
+}
+
+func TestValidatorResetLeavesNoStaleErrors(t *testing.T) {
+	reqs := makeSignupRequests(4)
+	v := &Validator{}
+	validateSignup(&reqs[3], v.Add)
+	if len(v.Errors()) != 5 {
+		t.Fatalf("got %d errors, want 5", len(v.Errors()))
+	}
+	v.Reset()
+	if len(v.Errors()) != 0 {
+		t.Fatalf("Errors() after Reset has %d entries", len(v.Errors()))
+	}
+	for i, err := range v.errs[:cap(v.errs)] {
+		if err != nil {
+			t.Fatalf("backing array slot %d still holds %v", i, err)
+		}
+	}
+	validateSignup(&reqs[0], v.Add)
+	if len(v.Errors()) != 0 {
+		t.Fatalf("valid request reported stale errors %v", v.Errors())
+	}
+	validateSignup(&reqs[1], v.Add)
+	if !sameErrors(v.Errors(), []error{errEmailFormat, errAgeRange}) {
+		t.Fatalf("got %v after reuse", v.Errors())
+	}
+}
+
+var (
+	signupBenchRequests = makeSignupRequests(1000)
+	validationErrCount  int
+)
+
+func TestValidatePooledAllocations(t *testing.T) {
+	count := func(errs []error) { validationErrCount += len(errs) }
+	validatePooled(&signupBenchRequests[3], count)
+	allocs := testing.AllocsPerRun(1000, func() {
+		validatePooled(&signupBenchRequests[3], count)
+	})
+	if allocs != 0 {
+		t.Fatalf("pooled validation allocated %v times", allocs)
+	}
+}
+
+func BenchmarkValidateFreshSlice(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for j := range signupBenchRequests {
+			n += len(validateFresh(&signupBenchRequests[j]))
+		}
+		validationErrCount = n
+	}
+}
+
+func BenchmarkValidatePooledCollector(b *testing.B) {
+	n := 0
+	count := func(errs []error) { n += len(errs) }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n = 0
+		for j := range signupBenchRequests {
+			validatePooled(&signupBenchRequests[j], count)
+		}
+		validationErrCount = n
+	}
+}
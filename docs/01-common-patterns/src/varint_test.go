@@ -0,0 +1,184 @@
+package perf
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// AppendVarint appends the protobuf-style base-128 varint encoding of x to
+// dst and returns the extended slice. It allocates only if dst lacks room.
+func AppendVarint(dst []byte, x uint64) []byte {
+	for x >= 0x80 {
+		dst = append(dst, byte(x)|0x80)
+		x >>= 7
+	}
+	return append(dst, byte(x))
+}
+
+// Varint decodes a varint from the start of buf and returns the value and
+// the number of bytes read. n == 0 means buf is truncated; n < 0 means the
+// encoding overflows 64 bits (-n bytes were examined).
+func Varint(buf []byte) (uint64, int) {
+	var x uint64
+	var s uint
+	for i, c := range buf {
+		if i == binary.MaxVarintLen64 {
+			return 0, -(i + 1)
+		}
+		if c < 0x80 {
+			if i == binary.MaxVarintLen64-1 && c > 1 {
+				return 0, -(i + 1)
+			}
+			return x | uint64(c)<<s, i + 1
+		}
+		x |= uint64(c&0x7f) << s
+		s += 7
+	}
+	return 0, 0
+}
+
+// encodeVarintAlloc returns a new slice per value, as many hand-written
+// encoders do.
+func encodeVarintAlloc(x uint64) []byte {
+	buf := make([]byte, 0, binary.MaxVarintLen64)
+	for x >= 0x80 {
+		buf = append(buf, byte(x)|0x80)
+		x >>= 7
+	}
+	return append(buf, byte(x))
+}
+
+var errVarint = errors.New("varint: truncated or overlong encoding")
+
+// decodeVarints reads count varints from buf into dst.
+func decodeVarints(dst []uint64, buf []byte, count int) ([]uint64, error) {
+	for i := 0; i < count; i++ {
+		v, n := Varint(buf)
+		if n <= 0 {
+			return dst, errVarint
+		}
+		dst = append(dst, v)
+		buf = buf[n:]
+	}
+	return dst, nil
+}
+
+var varintBoundaries = []uint64{
+	0, 1, 127,
+	1 << 7, 1<<7 + 1, 1<<14 - 1,
+	1 << 14, 1<<21 - 1, 1 << 21,
+	1 << 28, 1 << 35, 1 << 42, 1 << 49, 1 << 56,
+	1<<63 - 1, 1 << 63, math.MaxUint64,
+}
+
+func TestVarintRoundTrip(t *testing.T) {
+	for _, x := range varintBoundaries {
+		enc := AppendVarint(nil, x)
+		if want := binary.AppendUvarint(nil, x); string(enc) != string(want) {
+			t.Errorf("%d: encoded %x, encoding/binary gives %x", x, enc, want)
+		}
+		if string(encodeVarintAlloc(x)) != string(enc) {
+			t.Errorf("%d: allocating encoder disagrees", x)
+		}
+		got, n := Varint(enc)
+		if got != x || n != len(enc) {
+			t.Errorf("%d: decoded %d using %d bytes, want %d bytes", x, got, n, len(enc))
+		}
+	}
+}
+
+func TestVarintEncodedLengths(t *testing.T) {
+	cases := map[uint64]int{0: 1, 1<<7 - 1: 1, 1 << 7: 2, 1<<14 - 1: 2, 1 << 14: 3, math.MaxUint64: 10}
+	for x, want := range cases {
+		if got := len(AppendVarint(nil, x)); got != want {
+			t.Errorf("%d: %d bytes, want %d", x, got, want)
+		}
+	}
+}
+
+func TestVarintTruncated(t *testing.T) {
+	for _, x := range varintBoundaries {
+		enc := AppendVarint(nil, x)
+		for cut := 0; cut < len(enc); cut++ {
+			if v, n := Varint(enc[:cut]); n != 0 {
+				t.Errorf("%d cut to %d bytes: got (%d, %d), want n == 0", x, cut, v, n)
+			}
+		}
+	}
+}
+
+func TestVarintOverlong(t *testing.T) {
+	cases := [][]byte{
+		// Eleven bytes: more than any uint64 needs.
+		{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+		// Ten bytes, but the last one carries bits beyond bit 63.
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02},
+	}
+	for _, c := range cases {
+		if v, n := Varint(c); n >= 0 {
+			t.Errorf("%x: got (%d, %d), want n < 0", c, v, n)
+		}
+		if _, n := binary.Uvarint(c); n >= 0 {
+			t.Errorf("%x: encoding/binary accepts it, test case is wrong", c)
+		}
+	}
+}
+
+func TestAppendVarintReusesBuffer(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(1000, func() {
+		buf = buf[:0]
+		for _, x := range varintBoundaries[:6] {
+			buf = AppendVarint(buf, x)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("AppendVarint allocated %v times", allocs)
+	}
+
+	decoded, err := decodeVarints(nil, buf, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range decoded {
+		if v != varintBoundaries[i] {
+			t.Errorf("value %d: got %d, want %d", i, v, varintBoundaries[i])
+		}
+	}
+}
+
+var (
+	varintValues = func() []uint64 {
+		vals := make([]uint64, 1024)
+		x := uint64(88172645463325252)
+		for i := range vals {
+			x ^= x << 13
+			x ^= x >> 7
+			x ^= x << 17
+			vals[i] = x >> (x % 64) // spread across all encoded lengths
+		}
+		return vals
+	}()
+	varintSink []byte
+)
+
+func BenchmarkVarintEncodeAlloc(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, v := range varintValues {
+			varintSink = encodeVarintAlloc(v)
+		}
+	}
+}
+
+func BenchmarkVarintAppend(b *testing.B) {
+	buf := make([]byte, 0, len(varintValues)*binary.MaxVarintLen64)
+	for i := 0; i < b.N; i++ {
+		buf = buf[:0]
+		for _, v := range varintValues {
+			buf = AppendVarint(buf, v)
+		}
+		varintSink = buf
+	}
+}
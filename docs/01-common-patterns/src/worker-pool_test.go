@@ -4,10 +4,10 @@ import (
 	// "log"
 	"fmt"
 	// "os"
-	"runtime"
 	"sync"
 	"testing"
 	"crypto/sha256"
+	"encoding/binary"
 )
 
 const (
@@ -60,3 +60,101 @@ func BenchmarkWorkerPool(b *testing.B) {
 		wg.Wait()
 	}
 }
+
+// --- Result aggregation ---
+
+const numResultJobs = 100_000
+
+// resultWork is a CPU-bound task that, unlike doWork, doesn't allocate, so
+// the benchmarks below measure only the cost of collecting results.
+func resultWork(n int) [32]byte {
+	var in [8]byte
+	binary.LittleEndian.PutUint64(in[:], uint64(n))
+	return sha256.Sum256(in[:])
+}
+
+type indexedResult struct {
+	idx int
+	sum [32]byte
+}
+
+// collectViaChannel has workers send results over a buffered channel; a
+// single collector drains it into the preallocated slice.
+func collectViaChannel(results [][32]byte) {
+	jobs := make(chan int, workerCount*64)
+	out := make(chan indexedResult, workerCount*64)
+
+	var wg sync.WaitGroup
+	wg.Add(workerCount)
+	for w := 0; w < workerCount; w++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				out <- indexedResult{idx: job, sum: resultWork(job)}
+			}
+		}()
+	}
+	go func() {
+		for j := range results {
+			jobs <- j
+		}
+		close(jobs)
+		wg.Wait()
+		close(out)
+	}()
+
+	for r := range out {
+		results[r.idx] = r.sum
+	}
+}
+
+// collectViaIndex has each worker write directly into its job's slot. Slots
+// are disjoint, so no synchronization is needed beyond the final Wait.
+func collectViaIndex(results [][32]byte) {
+	jobs := make(chan int, workerCount*64)
+
+	var wg sync.WaitGroup
+	wg.Add(workerCount)
+	for w := 0; w < workerCount; w++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				results[job] = resultWork(job)
+			}
+		}()
+	}
+	for j := range results {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func TestWorkerPoolResultPositions(t *testing.T) {
+	for name, collect := range map[string]func([][32]byte){
+		"channel": collectViaChannel,
+		"index":   collectViaIndex,
+	} {
+		results := make([][32]byte, numResultJobs)
+		collect(results)
+		for i, got := range results {
+			if got != resultWork(i) {
+				t.Fatalf("%s: result %d is missing or misplaced", name, i)
+			}
+		}
+	}
+}
+
+func BenchmarkWorkerPoolResultChannel(b *testing.B) {
+	results := make([][32]byte, numResultJobs)
+	for i := 0; i < b.N; i++ {
+		collectViaChannel(results)
+	}
+}
+
+func BenchmarkWorkerPoolResultIndex(b *testing.B) {
+	results := make([][32]byte, numResultJobs)
+	for i := 0; i < b.N; i++ {
+		collectViaIndex(results)
+	}
+}
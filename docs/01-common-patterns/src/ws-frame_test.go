@@ -0,0 +1,353 @@
+package perf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// A simplified WebSocket binary frame: FIN+opcode, a mask bit with a 7-bit
+// length (126 means a 16-bit length follows), an optional 4-byte masking
+// key, then the payload. Clients mask, servers don't, as in RFC 6455.
+const (
+	wsFinBinary  = 0x82
+	wsMaskBit    = 0x80
+	wsLen16      = 126
+	maxWSPayload = 1<<16 - 1
+	wsMaxHeader  = 2 + 2 + 4
+)
+
+var errWSFrameTooLarge = errors.New("ws: payload too large")
+
+type wsHeader struct {
+	n      int
+	masked bool
+	key    [4]byte
+}
+
+// readWSHeader parses a frame header byte by byte. Reading into a local
+// array through io.ReadFull would make the array escape, costing an
+// allocation per frame.
+func readWSHeader(r *bufio.Reader) (wsHeader, error) {
+	var h wsHeader
+	if _, err := r.ReadByte(); err != nil {
+		return h, err
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return h, err
+	}
+	h.masked = b&wsMaskBit != 0
+	h.n = int(b &^ wsMaskBit)
+	if h.n == wsLen16 {
+		hi, err1 := r.ReadByte()
+		lo, err2 := r.ReadByte()
+		if err := errors.Join(err1, err2); err != nil {
+			return h, err
+		}
+		h.n = int(hi)<<8 | int(lo)
+	} else if h.n > wsLen16 {
+		return h, errWSFrameTooLarge
+	}
+	if h.masked {
+		for i := range h.key {
+			if h.key[i], err = r.ReadByte(); err != nil {
+				return h, err
+			}
+		}
+	}
+	return h, nil
+}
+
+func appendWSHeader(dst []byte, n int, key *[4]byte) []byte {
+	maskBit := byte(0)
+	if key != nil {
+		maskBit = wsMaskBit
+	}
+	if n < wsLen16 {
+		dst = append(dst, wsFinBinary, maskBit|byte(n))
+	} else {
+		dst = append(dst, wsFinBinary, maskBit|wsLen16)
+		dst = binary.BigEndian.AppendUint16(dst, uint16(n))
+	}
+	if key != nil {
+		dst = append(dst, key[:]...)
+	}
+	return dst
+}
+
+func maskWS(b []byte, key [4]byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+// --- Sized buffer pool ---
+
+// frameBuffer wraps a payload slice so the pool stores a pointer.
+type frameBuffer struct {
+	b []byte
+}
+
+// frameSizeClasses are the capacities the pool hands out. A frame uses the
+// smallest class that fits, so a 40-byte chat message doesn't hold a 64 KB
+// buffer.
+var frameSizeClasses = [...]int{256, 1 << 10, 4 << 10, 16 << 10, maxWSPayload + 1}
+
+type frameBufferPool struct {
+	classes [len(frameSizeClasses)]sync.Pool
+	poison  bool // overwrite buffers on Put, to expose use-after-recycle in tests
+}
+
+func newFrameBufferPool() *frameBufferPool {
+	p := &frameBufferPool{}
+	for i := range p.classes {
+		size := frameSizeClasses[i]
+		p.classes[i].New = func() any { return &frameBuffer{b: make([]byte, 0, size)} }
+	}
+	return p
+}
+
+func frameClass(n int) int {
+	for i, size := range frameSizeClasses {
+		if n <= size {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns a buffer of length n.
+func (p *frameBufferPool) Get(n int) *frameBuffer {
+	fb := p.classes[frameClass(n)].Get().(*frameBuffer)
+	fb.b = fb.b[:n]
+	return fb
+}
+
+func (p *frameBufferPool) Put(fb *frameBuffer) {
+	if p.poison {
+		b := fb.b[:cap(fb.b)]
+		for i := range b {
+			b[i] = 0xEE
+		}
+	}
+	if c := frameClass(cap(fb.b)); c >= 0 && frameSizeClasses[c] == cap(fb.b) {
+		p.classes[c].Put(fb)
+	}
+}
+
+// --- Server ---
+
+// serveWS echoes every frame back to the client until the connection
+// closes. With a nil pool, each payload gets a freshly allocated buffer.
+func serveWS(conn io.ReadWriter, pool *frameBufferPool) error {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var hdr []byte
+	for {
+		h, err := readWSHeader(r)
+		if err != nil {
+			if err == io.EOF || errors.Is(err, io.ErrClosedPipe) {
+				return nil
+			}
+			return err
+		}
+		var fb *frameBuffer
+		if pool != nil {
+			fb = pool.Get(h.n)
+		} else {
+			fb = &frameBuffer{b: make([]byte, h.n)}
+		}
+		if _, err := io.ReadFull(r, fb.b); err != nil {
+			return err
+		}
+		if h.masked {
+			maskWS(fb.b, h.key)
+		}
+
+		hdr = appendWSHeader(hdr[:0], len(fb.b), nil)
+		w.Write(hdr)
+		w.Write(fb.b)
+		err = w.Flush()
+		if pool != nil {
+			pool.Put(fb) // the payload has been written; nothing references it
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// --- Client ---
+
+type wsClient struct {
+	r    *bufio.Reader
+	w    *bufio.Writer
+	key  [4]byte
+	out  []byte
+	resp []byte
+}
+
+func newWSClient(conn io.ReadWriter) *wsClient {
+	return &wsClient{
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+		key:  [4]byte{0x12, 0x34, 0x56, 0x78},
+		out:  make([]byte, 0, wsMaxHeader+maxWSPayload),
+		resp: make([]byte, 0, maxWSPayload),
+	}
+}
+
+// RoundTrip sends payload as a masked frame and returns the echoed payload,
+// which is valid until the next call.
+func (c *wsClient) RoundTrip(payload []byte) ([]byte, error) {
+	c.out = appendWSHeader(c.out[:0], len(payload), &c.key)
+	start := len(c.out)
+	c.out = append(c.out, payload...)
+	maskWS(c.out[start:], c.key)
+	if _, err := c.w.Write(c.out); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	h, err := readWSHeader(c.r)
+	if err != nil {
+		return nil, err
+	}
+	c.resp = c.resp[:h.n]
+	_, err = io.ReadFull(c.r, c.resp)
+	return c.resp, err
+}
+
+// startWSPair connects a client to a new server over an in-memory pipe.
+func startWSPair(pool *frameBufferPool) (*wsClient, func() error) {
+	clientConn, serverConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- serveWS(serverConn, pool) }()
+	return newWSClient(clientConn), func() error {
+		clientConn.Close()
+		err := <-done
+		serverConn.Close()
+		return err
+	}
+}
+
+func wsPayload(n, seed int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(seed*31 + i)
+	}
+	return p
+}
+
+var wsTestSizes = []int{0, 1, 40, 125, 126, 255, 256, 257, 1000, 4096, 20_000, maxWSPayload}
+
+func TestWSFrameRoundTrip(t *testing.T) {
+	for name, pool := range map[string]*frameBufferPool{"alloc": nil, "pooled": newFrameBufferPool()} {
+		client, stop := startWSPair(pool)
+		for i, n := range wsTestSizes {
+			want := wsPayload(n, i)
+			got, err := client.RoundTrip(want)
+			if err != nil {
+				t.Fatalf("%s: %d-byte frame: %v", name, n, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: %d-byte frame echoed incorrectly", name, n)
+			}
+		}
+		if err := stop(); err != nil {
+			t.Fatalf("%s: server: %v", name, err)
+		}
+	}
+}
+
+func TestWSPooledConcurrentConnections(t *testing.T) {
+	pool := newFrameBufferPool()
+	pool.poison = true
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for c := 0; c < 8; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, stop := startWSPair(pool)
+			defer stop()
+			for i := 0; i < 300; i++ {
+				n := wsTestSizes[(i+c)%len(wsTestSizes)]
+				want := wsPayload(n, i*8+c)
+				got, err := client.RoundTrip(want)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(got, want) {
+					errs <- fmt.Errorf("connection %d message %d: %d-byte payload corrupted", c, i, n)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestFrameBufferPoolSizeClasses(t *testing.T) {
+	p := newFrameBufferPool()
+	for _, n := range []int{0, 256, 257, 5000, maxWSPayload} {
+		fb := p.Get(n)
+		if len(fb.b) != n || cap(fb.b) != frameSizeClasses[frameClass(n)] {
+			t.Fatalf("Get(%d): len %d cap %d", n, len(fb.b), cap(fb.b))
+		}
+		p.Put(fb)
+	}
+	p.Put(&frameBuffer{b: make([]byte, 0, 300)}) // odd capacity: dropped
+	if fb := p.Get(300); cap(fb.b) != 1<<10 {
+		t.Fatalf("pool returned a buffer with foreign capacity %d", cap(fb.b))
+	}
+}
+
+var wsBenchPayloads = func() [][]byte {
+	sizes := []int{32, 120, 300, 900, 2000}
+	out := make([][]byte, len(sizes))
+	for i, n := range sizes {
+		out[i] = wsPayload(n, i)
+	}
+	return out
+}()
+
+// benchmarkWS runs one echo round trip per operation. Each parallel worker
+// has its own connection, as separate WebSocket clients would.
+func benchmarkWS(b *testing.B, pool *frameBufferPool) {
+	var failed atomic.Bool
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		client, stop := startWSPair(pool)
+		defer stop()
+		i := 0
+		for pb.Next() {
+			if _, err := client.RoundTrip(wsBenchPayloads[i%len(wsBenchPayloads)]); err != nil {
+				failed.Store(true)
+				return
+			}
+			i++
+		}
+	})
+	if failed.Load() {
+		b.Fatal("round trip failed")
+	}
+}
+
+func BenchmarkWSFrameAlloc(b *testing.B) { benchmarkWS(b, nil) }
+
+func BenchmarkWSFramePooled(b *testing.B) { benchmarkWS(b, newFrameBufferPool()) }